@@ -0,0 +1,322 @@
+package dekopin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/spf13/cobra"
+)
+
+const (
+	BLUE_TAG  = "blue"  // The revision that was live before the last promote, the fallback
+	GREEN_TAG = "green" // The candidate revision
+)
+
+var bluegreenCmd = &cobra.Command{
+	Use:   "bluegreen",
+	Short: "Deploy with blue and green tags and an explicit promote step",
+}
+
+var bluegreenDeployCmd = &cobra.Command{
+	Use:     "deploy",
+	Short:   "Deploy a new revision without traffic and tag it green",
+	PreRunE: bluegreenDeployPreRun,
+	RunE:    bluegreenDeployCommand,
+}
+
+var bluegreenPromoteCmd = &cobra.Command{
+	Use:     "promote",
+	Short:   "Move traffic to green and tag the previously live revision blue",
+	PreRunE: bluegreenPromotePreRun,
+	RunE:    bluegreenPromoteCommand,
+}
+
+var bluegreenAbortCmd = &cobra.Command{
+	Use:   "abort",
+	Short: "Remove the green tag without moving traffic",
+	RunE:  bluegreenAbortCommand,
+}
+
+// BlueGreenState is the live revision of a service and the revisions behind the blue and green tags.
+type BlueGreenState struct {
+	Live  string
+	Blue  string
+	Green string
+}
+
+// NewBlueGreenState reads the state from the traffic of a service.
+func NewBlueGreenState(service *runpb.Service) BlueGreenState {
+	state := BlueGreenState{}
+	var livePercent int32
+	for _, t := range service.GetTrafficStatuses() {
+		revision := path.Base(t.GetRevision())
+		if t.GetType() == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			revision = path.Base(service.GetLatestReadyRevision())
+		}
+
+		if t.GetPercent() > livePercent {
+			state.Live, livePercent = revision, t.GetPercent()
+		}
+
+		switch t.GetTag() {
+		case BLUE_TAG:
+			state.Blue = revision
+		case GREEN_TAG:
+			state.Green = revision
+		}
+	}
+	return state
+}
+
+// PromoteTags returns the tags to set when promoting green: the revision that was live becomes
+// blue, the fallback. The green tag is removed, as its revision is then live.
+func (s BlueGreenState) PromoteTags() (map[string]string, error) {
+	if s.Green == "" {
+		return nil, errors.New("no green revision. Run dekopin bluegreen deploy first")
+	}
+	if s.Green == s.Live {
+		return nil, fmt.Errorf("green revision %s is already live", s.Green)
+	}
+
+	tags := map[string]string{}
+	if s.Live != "" {
+		tags[BLUE_TAG] = s.Live
+	}
+	return tags, nil
+}
+
+func bluegreenDeployPreRun(cmd *cobra.Command, args []string) error {
+	dekopinCmd, err := GetDekopinCommand(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	image, err := dekopinCmd.GetImageByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get image flag: %w", err)
+	}
+
+	opt, err := GetCmdOption(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	return checkImagePolicy(cmd, policyImages(opt, image))
+}
+
+func bluegreenDeployCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	image, err := dekopinCmd.GetImageByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get image flag: %w", err)
+	}
+
+	secretFlags, err := dekopinCmd.GetSecretsByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get secret flag: %w", err)
+	}
+
+	secrets, err := ParseSecretFlags(secretFlags)
+	if err != nil {
+		return err
+	}
+
+	commitHash, err := GetCommitHash(ctx)
+	if err != nil {
+		if !errors.Is(err, ErrGetCommitHashInLocal) {
+			return err
+		}
+	}
+
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if commitHash == "" && len(opt.Regions) > 0 {
+		commitHash = NewRevisionSuffix(time.Now())
+	}
+
+	force, err := dekopinCmd.GetForceByFlag()
+	if err != nil {
+		return err
+	}
+
+	ctx = SetForce(ctx, force)
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		serviceImage := image
+		if service, ok := GetServiceConfig(ctx); ok && serviceImage == "" {
+			serviceImage = service.Image
+		}
+		if serviceImage == "" {
+			return fmt.Errorf("image is required")
+		}
+
+		ctx, err := withRevisionSpec(ctx, secrets)
+		if err != nil {
+			return err
+		}
+
+		resolved, err := ResolveImage(ctx, serviceImage)
+		if err != nil {
+			return err
+		}
+
+		if err := VerifyImage(ctx, resolved); err != nil {
+			return err
+		}
+
+		ctx = WithHookVars(ctx, map[string]string{"TAG": GREEN_TAG, "IMAGE": resolved})
+		return RunForEachRegion(ctx, opt.Waves, func(ctx context.Context) error {
+			return bluegreenDeploy(ctx, gc, serviceImage, resolved, commitHash)
+		})
+	})
+}
+
+func bluegreenDeploy(ctx context.Context, gc GCloud, sourceImage string, image string, commitHash string) error {
+	// The blue tag stays on the fallback of the last promote until the next one.
+	if err := gc.CreateRevision(ctx, image, commitHash); err != nil {
+		return fmt.Errorf("failed to create revision: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return err
+	}
+	green := path.Base(service.GetLatestCreatedRevision())
+
+	if err := gc.UpdateRevisionTags(ctx, map[string]string{GREEN_TAG: green}); err != nil {
+		return fmt.Errorf("failed to tag the new revision green: %w", err)
+	}
+
+	if err := recordSourceImage(ctx, gc, sourceImage, image); err != nil {
+		return fmt.Errorf("failed to record source image: %w", err)
+	}
+
+	if err := smokeCheckTag(ctx, gc, GetHTTPClient(ctx), GREEN_TAG); err != nil {
+		return err
+	}
+
+	url, err := TagURL(ctx, gc, GREEN_TAG)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout(ctx), "Revision %s is tagged %s: %s\n", green, GREEN_TAG, url)
+
+	return nil
+}
+
+func bluegreenPromotePreRun(cmd *cobra.Command, args []string) error {
+	return checkFreeze(cmd)
+}
+
+func bluegreenPromoteCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	force, err := dekopinCmd.GetForceByFlag()
+	if err != nil {
+		return err
+	}
+
+	ctx = SetForce(ctx, force)
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return bluegreenPromote(ctx, gc)
+		})
+	})
+}
+
+func bluegreenPromote(ctx context.Context, gc GCloud) error {
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return err
+	}
+
+	state := NewBlueGreenState(service)
+	tags, err := state.PromoteTags()
+	if err != nil {
+		return err
+	}
+
+	if err := gc.UpdateTrafficToRevisionTag(ctx, GREEN_TAG); err != nil {
+		return fmt.Errorf("failed to update traffic to revision tag: %w", err)
+	}
+
+	if len(tags) > 0 {
+		if err := gc.UpdateRevisionTags(ctx, tags); err != nil {
+			return fmt.Errorf("failed to tag the previously live revision blue: %w", err)
+		}
+	}
+
+	if err := gc.RemoveRevisionTag(ctx, GREEN_TAG); err != nil {
+		return fmt.Errorf("failed to remove revision tag: %w", err)
+	}
+
+	if state.Live == "" {
+		fmt.Fprintf(Stdout(ctx), "Promoted %s\n", state.Green)
+		return nil
+	}
+	fmt.Fprintf(Stdout(ctx), "Promoted %s. %s is the fallback, tagged %s\n", state.Green, state.Live, BLUE_TAG)
+	return nil
+}
+
+func bluegreenAbortCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		ctx = WithHookVars(ctx, map[string]string{"TAG": GREEN_TAG})
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return bluegreenAbort(ctx, gc)
+		})
+	})
+}
+
+func bluegreenAbort(ctx context.Context, gc GCloud) error {
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return err
+	}
+
+	state := NewBlueGreenState(service)
+	if state.Green == "" {
+		return errors.New("no green revision to abort")
+	}
+	if state.Green == state.Live {
+		return fmt.Errorf("green revision %s is live. Run dekopin st-deploy --tag %s to roll back to the fallback", state.Green, BLUE_TAG)
+	}
+
+	if err := gc.RemoveRevisionTag(ctx, GREEN_TAG); err != nil {
+		return fmt.Errorf("failed to remove revision tag: %w", err)
+	}
+
+	fmt.Fprintf(Stdout(ctx), "Removed the %s tag from %s\n", GREEN_TAG, state.Green)
+	return nil
+}
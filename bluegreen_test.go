@@ -0,0 +1,101 @@
+package dekopin_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlueGreenStatePromoteTags(t *testing.T) {
+	type TestResult struct {
+		State dekopin.BlueGreenState
+		Tags  map[string]string
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		service *runpb.Service
+	}
+
+	revision := runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_live_becomes_blue_the_fallback": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{service: &runpb.Service{
+					TrafficStatuses: []*runpb.TrafficTargetStatus{
+						{Type: revision, Revision: "svc-a", Percent: 100, Tag: "blue"},
+						{Type: revision, Revision: "svc-b", Tag: "green"},
+					},
+				}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, dekopin.BlueGreenState{Live: "svc-a", Blue: "svc-a", Green: "svc-b"}, result.State)
+				assert.Equal(t, map[string]string{"blue": "svc-a"}, result.Tags)
+			},
+		},
+		"success_latest_traffic_is_resolved_to_the_ready_revision": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{service: &runpb.Service{
+					LatestReadyRevision: "projects/p/locations/r/services/svc/revisions/svc-a",
+					TrafficStatuses: []*runpb.TrafficTargetStatus{
+						{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 100},
+						{Type: revision, Revision: "svc-b", Tag: "green"},
+					},
+				}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, map[string]string{"blue": "svc-a"}, result.Tags)
+			},
+		},
+		"success_blue_moves_from_the_older_fallback_to_the_live_revision": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{service: &runpb.Service{
+					TrafficStatuses: []*runpb.TrafficTargetStatus{
+						{Type: revision, Revision: "svc-a", Tag: "blue"},
+						{Type: revision, Revision: "svc-b", Percent: 100},
+						{Type: revision, Revision: "svc-c", Tag: "green"},
+					},
+				}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, dekopin.BlueGreenState{Live: "svc-b", Blue: "svc-a", Green: "svc-c"}, result.State)
+				assert.Equal(t, map[string]string{"blue": "svc-b"}, result.Tags)
+			},
+		},
+		"error_without_green": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{service: &runpb.Service{
+					TrafficStatuses: []*runpb.TrafficTargetStatus{{Type: revision, Revision: "svc-a", Percent: 100, Tag: "blue"}},
+				}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "no green revision")
+			},
+		},
+		"error_green_already_live": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{service: &runpb.Service{
+					TrafficStatuses: []*runpb.TrafficTargetStatus{{Type: revision, Revision: "svc-b", Percent: 100, Tag: "green"}},
+				}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "already live")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			state := dekopin.NewBlueGreenState(ar.service)
+			tags, err := state.PromoteTags()
+			c.Assert(t, ar, TestResult{State: state, Tags: tags, Err: err})
+		})
+	}
+}
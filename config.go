@@ -1,16 +1,32 @@
 package dekopin
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 
 	"gopkg.in/yaml.v3"
 )
 
-func getConfig(fileName string) (*DekopinConfig, error) {
-	dekopinYaml, err := os.ReadFile(fileName)
+const (
+	CONFIG_INCLUDE_KEY = "include"
+)
+
+// LoadConfig reads the configuration file, including the files it includes.
+func LoadConfig(fileName string) (*DekopinConfig, error) {
+	merged, err := loadConfigFile(fileName, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
+	}
+
+	dekopinYaml, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge configuration files: %w", err)
 	}
 
 	var config *DekopinConfig
@@ -21,11 +37,265 @@ func getConfig(fileName string) (*DekopinConfig, error) {
 	return config, nil
 }
 
+// loadConfigFile reads a configuration file with environment variables expanded and
+// the files listed in its include key deep-merged underneath it.
+func loadConfigFile(fileName string, visited []string) (map[string]any, error) {
+	if slices.Contains(visited, fileName) {
+		return nil, fmt.Errorf("circular include of configuration file %s", fileName)
+	}
+	visited = append(visited, fileName)
+
+	dekopinYaml, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(dekopinYaml, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", fileName, err)
+	}
+
+	if err := expandEnvInNode(&node); err != nil {
+		return nil, fmt.Errorf("failed to expand configuration file %s: %w", fileName, err)
+	}
+
+	doc := map[string]any{}
+	if node.Kind == 0 {
+		return doc, nil
+	}
+
+	if errs := checkUnknownKeys(fileName, &node, reflect.TypeOf(DekopinConfig{}), CONFIG_INCLUDE_KEY); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var typed DekopinConfig
+	if err := node.Decode(&typed); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", fileName, err)
+	}
+
+	if err := node.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", fileName, err)
+	}
+
+	includes, err := configIncludes(doc[CONFIG_INCLUDE_KEY])
+	if err != nil {
+		return nil, fmt.Errorf("invalid include in %s: %w", fileName, err)
+	}
+	delete(doc, CONFIG_INCLUDE_KEY)
+
+	base := map[string]any{}
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(fileName), include)
+		}
+
+		included, err := loadConfigFile(include, visited)
+		if err != nil {
+			return nil, err
+		}
+		base = mergeConfig(base, included)
+	}
+
+	return mergeConfig(base, doc), nil
+}
+
+func configIncludes(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		includes := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include must be a file name or a list of file names")
+			}
+			includes = append(includes, s)
+		}
+		return includes, nil
+	default:
+		return nil, fmt.Errorf("include must be a file name or a list of file names")
+	}
+}
+
+// mergeConfig deep-merges override into base. Maps are merged key by key,
+// any other value in override replaces the one in base.
+func mergeConfig(base map[string]any, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range override {
+		baseMap, baseIsMap := merged[k].(map[string]any)
+		overrideMap, overrideIsMap := v.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			merged[k] = mergeConfig(baseMap, overrideMap)
+			continue
+		}
+		merged[k] = v
+	}
+
+	return merged
+}
+
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces ${VAR} and ${VAR:-default} with the value of the environment variable.
+// An unset variable without a default is an error rather than an empty string.
+func ExpandEnv(value string) (string, error) {
+	var expandErr error
+	expanded := envVarPattern.ReplaceAllStringFunc(value, func(m string) string {
+		sub := envVarPattern.FindStringSubmatch(m)
+		v, ok := os.LookupEnv(sub[1])
+		if sub[2] != "" {
+			if v == "" {
+				return sub[3]
+			}
+			return v
+		}
+		if ok {
+			return v
+		}
+		if expandErr == nil {
+			expandErr = fmt.Errorf("environment variable %s is not set", sub[1])
+		}
+		return ""
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return expanded, nil
+}
+
+func expandEnvInNode(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		expanded, err := ExpandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if expanded != node.Value && node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 {
+			// The tag was resolved from the ${...} text. An unquoted value is resolved again,
+			// so that concurrency: ${CONCURRENCY:-4} is an int.
+			node.Tag = ""
+		}
+		node.Value = expanded
+		return nil
+	}
+
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "hooks" {
+				if err := expandEnvInHooks(value); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := expandEnvInNode(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, child := range node.Content {
+		if err := expandEnvInNode(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandEnvInHooks expands every value of the hooks except the run commands. They run with sh -c,
+// which expands them with the DEKOPIN_* variables of the hook, unknown when the file is loaded.
+func expandEnvInHooks(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "run" && value.Kind == yaml.ScalarNode {
+				continue
+			}
+			if err := expandEnvInHooks(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if node.Kind == yaml.ScalarNode {
+		return expandEnvInNode(node)
+	}
+
+	for _, child := range node.Content {
+		if err := expandEnvInHooks(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type dekopinConfigKey struct{}
+
+func SetDekopinConfig(ctx context.Context, config *DekopinConfig) context.Context {
+	return context.WithValue(ctx, dekopinConfigKey{}, config)
+}
+
+// GetDekopinConfig returns the loaded configuration file, or an empty configuration
+// when no file was used.
+func GetDekopinConfig(ctx context.Context) *DekopinConfig {
+	config, ok := ctx.Value(dekopinConfigKey{}).(*DekopinConfig)
+	if !ok || config == nil {
+		return &DekopinConfig{}
+	}
+	return config
+}
+
 type DekopinConfig struct {
-	Project string `yaml:"project"`
-	Region  string `yaml:"region"`
-	Service string `yaml:"service"`
-	Runner  string `yaml:"runner"`
+	Project     string          `yaml:"project,omitempty"`
+	Region      string          `yaml:"region,omitempty"`
+	Regions     []string        `yaml:"regions,omitempty"`
+	Waves       bool            `yaml:"waves,omitempty"`
+	Service     string          `yaml:"service,omitempty"`
+	Runner      string          `yaml:"runner,omitempty"`
+	Services    []ServiceConfig `yaml:"services,omitempty"`
+	Concurrency int             `yaml:"concurrency,omitempty"`
+	Spec        *ServiceSpec    `yaml:"spec,omitempty"`
+
+	Environment  string        `yaml:"environment,omitempty"`
+	SecretPolicy *SecretPolicy `yaml:"secret_policy,omitempty"`
+	ImagePolicy  *ImagePolicy  `yaml:"image_policy,omitempty"`
+
+	SignaturePolicy *SignaturePolicy `yaml:"signature_policy,omitempty"`
+
+	Smoke *SmokeConfig `yaml:"smoke,omitempty"`
+	Watch *WatchConfig `yaml:"watch,omitempty"`
+
+	Hooks map[string]*CommandHooks `yaml:"hooks,omitempty"`
+
+	ProtectedTags []string `yaml:"protected_tags,omitempty"`
+
+	Freeze  []FreezeWindow `yaml:"freeze,omitempty"`
+	History *HistoryConfig `yaml:"history,omitempty"`
+
+	Notifications []Notification `yaml:"notifications,omitempty"`
+	GitHub        *GitHubConfig  `yaml:"github,omitempty"`
+}
+
+// ServiceConfig describes one Cloud Run service when dekopin.yml manages several of them.
+// Image, region and the tag policy act as defaults for the command flags.
+type ServiceConfig struct {
+	Name       string       `yaml:"name"`
+	Image      string       `yaml:"image,omitempty"`
+	Region     string       `yaml:"region,omitempty"`
+	Tag        string       `yaml:"tag,omitempty"`
+	CreateTag  bool         `yaml:"create_tag,omitempty"`
+	RemoveTags bool         `yaml:"remove_tags,omitempty"`
+	Spec       *ServiceSpec `yaml:"spec,omitempty"`
 }
 
 const (
@@ -38,6 +308,13 @@ const (
 
 	ENV_GITHUB_SHA      = "GITHUB_SHA"
 	ENV_CLOUD_BUILD_SHA = "COMMIT_SHA"
+
+	ENV_GITHUB_SERVER_URL = "GITHUB_SERVER_URL"
+	ENV_GITHUB_REPOSITORY = "GITHUB_REPOSITORY"
+	ENV_GITHUB_RUN_ID     = "GITHUB_RUN_ID"
+	ENV_GITHUB_TOKEN      = "GITHUB_TOKEN"
+	ENV_GITHUB_API_URL    = "GITHUB_API_URL"
+	ENV_CLOUD_BUILD_ID    = "BUILD_ID"
 )
 
 var ValidRunners = []string{
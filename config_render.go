@@ -0,0 +1,46 @@
+package dekopin
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the dekopin configuration",
+}
+
+var configRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the effective configuration after flags, environment variables and includes are applied",
+	RunE:  configRenderCommand,
+}
+
+func configRenderCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	out, err := renderConfig(opt)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// renderConfig prints the resolved command options as a configuration.
+func renderConfig(opt *CmdOption) ([]byte, error) {
+	out, err := yaml.Marshal(opt.Config())
+	if err != nil {
+		return nil, fmt.Errorf("failed to render configuration: %w", err)
+	}
+
+	return out, nil
+}
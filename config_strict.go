@@ -0,0 +1,122 @@
+package dekopin
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkUnknownKeys reports every mapping key in node that has no matching yaml field in t,
+// with the line it appears on. Keys in allowed are accepted at the top level only.
+func checkUnknownKeys(fileName string, node *yaml.Node, t reflect.Type, allowed ...string) []error {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	errs := []error{}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				if !slices.Contains(allowed, key.Value) {
+					errs = append(errs, unknownKeyError(fileName, key, fields))
+				}
+				continue
+			}
+			errs = append(errs, checkUnknownKeys(fileName, value, fieldType)...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for _, item := range node.Content {
+			errs = append(errs, checkUnknownKeys(fileName, item, t.Elem())...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, checkUnknownKeys(fileName, node.Content[i], t.Elem())...)
+		}
+	}
+
+	return errs
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func unknownKeyError(fileName string, key *yaml.Node, fields map[string]reflect.Type) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	// Only suggest names close enough to be a typo.
+	suggestion := ""
+	best := len(key.Value)/2 + 1
+	for _, name := range names {
+		if d := editDistance(key.Value, name); d < best {
+			best, suggestion = d, name
+		}
+	}
+
+	if suggestion != "" {
+		return fmt.Errorf("%s:%d: unknown key %q (did you mean %q?)", fileName, key.Line, key.Value, suggestion)
+	}
+	return fmt.Errorf("%s:%d: unknown key %q", fileName, key.Line, key.Value)
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
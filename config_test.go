@@ -0,0 +1,274 @@
+package dekopin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandEnv(t *testing.T) {
+	type TestResult struct {
+		Value string
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		value    string
+		expected string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_expands_set_variable": {
+			Arrange: func() ArrangeResult {
+				t.Setenv("DEKOPIN_TEST_PROJECT", "my-project")
+				return ArrangeResult{
+					value:    "${DEKOPIN_TEST_PROJECT}",
+					expected: "my-project",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, assertArgs.expected, result.Value)
+			},
+		},
+		"success_uses_default_for_unset_variable": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					value:    "gcr.io/${DEKOPIN_TEST_UNSET:-fallback}/image",
+					expected: "gcr.io/fallback/image",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, assertArgs.expected, result.Value)
+			},
+		},
+		"success_leaves_plain_value_as_is": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					value:    "$HOME and plain text",
+					expected: "$HOME and plain text",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, assertArgs.expected, result.Value)
+			},
+		},
+		"error_unset_variable_without_default": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					value: "${DEKOPIN_TEST_UNSET}",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Error(t, result.Err)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			value, err := dekopin.ExpandEnv(ar.value)
+			c.Assert(t, ar, TestResult{
+				Value: value,
+				Err:   err,
+			})
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	type TestResult struct {
+		Config *dekopin.DekopinConfig
+		Err    error
+	}
+
+	type ArrangeResult struct {
+		fileName string
+	}
+
+	writeFile := func(t *testing.T, dir string, name string, content string) string {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_include_is_deep_merged_under_the_file": {
+			Arrange: func() ArrangeResult {
+				t.Setenv("DEKOPIN_TEST_PROJECT", "env-project")
+				dir := t.TempDir()
+				writeFile(t, dir, "base.yml", "project: base-project\nregion: asia-northeast1\nrunner: local\n")
+				return ArrangeResult{
+					fileName: writeFile(t, dir, "dekopin.yml", "include: base.yml\nproject: ${DEKOPIN_TEST_PROJECT}\nservice: my-service\n"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, &dekopin.DekopinConfig{
+					Project: "env-project",
+					Region:  "asia-northeast1",
+					Service: "my-service",
+					Runner:  dekopin.RUNNER_LOCAL,
+				}, result.Config)
+			},
+		},
+		"success_smoke_check_durations": {
+			Arrange: func() ArrangeResult {
+				dir := t.TempDir()
+				return ArrangeResult{
+					fileName: writeFile(t, dir, "dekopin.yml", "smoke:\n  checks:\n    - path: /healthz\n      timeout: 5s\n      interval: 500ms\n      retries: 3\n"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, &dekopin.SmokeConfig{
+					Checks: []dekopin.SmokeCheck{{Path: "/healthz", Timeout: 5 * time.Second, Interval: 500 * time.Millisecond, Retries: 3}},
+				}, result.Config.Smoke)
+			},
+		},
+		"success_expanded_values_keep_their_types": {
+			Arrange: func() ArrangeResult {
+				t.Setenv("DEKOPIN_TEST_WAVES", "true")
+				dir := t.TempDir()
+				return ArrangeResult{
+					fileName: writeFile(t, dir, "dekopin.yml", "concurrency: ${DEKOPIN_TEST_UNSET:-4}\nwaves: ${DEKOPIN_TEST_WAVES}\nenvironment: \"${DEKOPIN_TEST_UNSET:-1}\"\n"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, &dekopin.DekopinConfig{Concurrency: 4, Waves: true, Environment: "1"}, result.Config)
+			},
+		},
+		"success_hook_run_is_left_to_the_shell": {
+			Arrange: func() ArrangeResult {
+				t.Setenv("DEKOPIN_TEST_TIMEOUT", "1m")
+				dir := t.TempDir()
+				return ArrangeResult{
+					fileName: writeFile(t, dir, "dekopin.yml", "hooks:\n  deploy:\n    post:\n      - run: echo \"${DEKOPIN_TAG}\" ${DEKOPIN_TEST_UNSET}\n        timeout: ${DEKOPIN_TEST_TIMEOUT}\n"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []dekopin.Hook{{Run: `echo "${DEKOPIN_TAG}" ${DEKOPIN_TEST_UNSET}`, Timeout: time.Minute}}, result.Config.Hooks["deploy"].Post)
+			},
+		},
+		"error_unknown_key_is_reported_with_line_number": {
+			Arrange: func() ArrangeResult {
+				dir := t.TempDir()
+				return ArrangeResult{
+					fileName: writeFile(t, dir, "dekopin.yml", "project: my-project\nservcie: my-service\n"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, `dekopin.yml:2: unknown key "servcie" (did you mean "service"?)`)
+			},
+		},
+		"error_unknown_key_in_service": {
+			Arrange: func() ArrangeResult {
+				dir := t.TempDir()
+				return ArrangeResult{
+					fileName: writeFile(t, dir, "dekopin.yml", "services:\n  - name: api\n    imgae: gcr.io/p/api\n"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, `dekopin.yml:3: unknown key "imgae"`)
+			},
+		},
+		"error_circular_include": {
+			Arrange: func() ArrangeResult {
+				dir := t.TempDir()
+				writeFile(t, dir, "a.yml", "include: b.yml\n")
+				writeFile(t, dir, "b.yml", "include: a.yml\n")
+				return ArrangeResult{
+					fileName: filepath.Join(dir, "a.yml"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "circular include")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			config, err := dekopin.LoadConfig(ar.fileName)
+			c.Assert(t, ar, TestResult{
+				Config: config,
+				Err:    err,
+			})
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		config *dekopin.DekopinConfig
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_valid_config": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					config: &dekopin.DekopinConfig{
+						Project:  "my-project",
+						Region:   "asia-northeast1",
+						Runner:   dekopin.RUNNER_GITHUB_ACTIONS,
+						Services: []dekopin.ServiceConfig{{Name: "api", Tag: "staging"}},
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_invalid_runner_and_duplicate_service_are_both_reported": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					config: &dekopin.DekopinConfig{
+						Runner:   "jenkins",
+						Services: []dekopin.ServiceConfig{{Name: "api"}, {Name: "api"}},
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "invalid runner")
+				assert.ErrorContains(t, result.Err, "duplicate service api")
+			},
+		},
+		"error_invalid_protected_tag_pattern": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					config: &dekopin.DekopinConfig{ProtectedTags: []string{"stable", "qa-[", ""}},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, `protected_tags[1]: invalid pattern "qa-["`)
+				assert.ErrorContains(t, result.Err, "protected_tags[2]: must not be empty")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			err := dekopin.ValidateConfig(ar.config)
+			c.Assert(t, ar, TestResult{
+				Err: err,
+			})
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package dekopin
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed schema/dekopin.schema.json
+var configSchema []byte
+
+var configValidateCmd = &cobra.Command{
+	Use:               "validate",
+	Short:             "Validate the configuration file",
+	Annotations:       map[string]string{ANNOTATION_OFFLINE: "true"},
+	PersistentPreRunE: prepareOfflineRun,
+	RunE:              configValidateCommand,
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:               "schema",
+	Short:             "Print the JSON Schema of the configuration file",
+	Annotations:       map[string]string{ANNOTATION_OFFLINE: "true"},
+	PersistentPreRunE: prepareOfflineRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := os.Stdout.Write(configSchema)
+		return err
+	},
+}
+
+func configValidateCommand(cmd *cobra.Command, args []string) error {
+	fileName, err := NewDekopinCommand(cmd).GetFileByFlag()
+	if err != nil {
+		return err
+	}
+
+	config, err := LoadConfig(fileName)
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateConfig(config); err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	fmt.Printf("%s is valid\n", fileName)
+	return nil
+}
+
+// ValidateConfig checks the values of a configuration file. Required values are not checked
+// here because they may still be given by flags.
+func ValidateConfig(config *DekopinConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	errs := []error{}
+	if config.Runner != "" && !slices.Contains(ValidRunners, config.Runner) {
+		errs = append(errs, fmt.Errorf("invalid runner %q. Valid values: github-actions, cloud-build, local", config.Runner))
+	}
+
+	if config.Region != "" && len(config.Regions) > 0 {
+		errs = append(errs, fmt.Errorf("region and regions cannot be used together"))
+	}
+
+	if config.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("concurrency must not be negative"))
+	}
+
+	if err := config.Spec.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := config.ImagePolicy.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := config.SignaturePolicy.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := config.Smoke.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := config.Watch.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateHooks(config.Hooks); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateProtectedTags(config.ProtectedTags); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateFreezeWindows(config.Freeze); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := config.History.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateNotifications(config.Notifications); err != nil {
+		errs = append(errs, err)
+	}
+
+	if config.Spec != nil {
+		if err := config.SecretPolicy.Check(config.Spec.Secrets, config.Environment); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	names := []string{}
+	for i, s := range config.Services {
+		if s.Name == "" {
+			errs = append(errs, fmt.Errorf("services[%d]: name is required", i))
+			continue
+		}
+		if slices.Contains(names, s.Name) {
+			errs = append(errs, fmt.Errorf("services[%d]: duplicate service %s", i, s.Name))
+		}
+		names = append(names, s.Name)
+
+		if err := ValidateTag(s.Tag); err != nil {
+			errs = append(errs, fmt.Errorf("services[%d]: %w", i, err))
+		}
+		if err := MergeServiceSpec(config.Spec, s.Spec).Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("services[%d]: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
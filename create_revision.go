@@ -4,14 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var createRevisionCmd = &cobra.Command{
-	Use:   "create-revision",
-	Short: "Create a new Cloud Run revision",
-	RunE:  createRevisionCommand,
+	Use:     "create-revision",
+	Short:   "Create a new Cloud Run revision",
+	PreRunE: createRevisionPreRun,
+	RunE:    createRevisionCommand,
+}
+
+func createRevisionPreRun(cmd *cobra.Command, args []string) error {
+	dekopinCmd, err := GetDekopinCommand(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	image, err := dekopinCmd.GetImageByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get image flag: %w", err)
+	}
+
+	return checkImagePolicy(cmd, []string{image})
 }
 
 func createRevisionCommand(cmd *cobra.Command, args []string) error {
@@ -31,6 +48,16 @@ func createRevisionCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get image flag: %w", err)
 	}
 
+	secretFlags, err := dekopinCmd.GetSecretsByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get secret flag: %w", err)
+	}
+
+	secrets, err := ParseSecretFlags(secretFlags)
+	if err != nil {
+		return err
+	}
+
 	commitHash, err := GetCommitHash(ctx)
 	if err != nil {
 		if !errors.Is(err, ErrGetCommitHashInLocal) {
@@ -38,13 +65,50 @@ func createRevisionCommand(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	return createRevision(ctx, gc, image, commitHash)
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	waves, err := dekopinCmd.GetWavesByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get waves flag: %w", err)
+	}
+
+	if commitHash == "" && len(opt.Regions) > 0 {
+		commitHash = NewRevisionSuffix(time.Now())
+	}
+
+	resolved, err := ResolveImage(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	if err := VerifyImage(ctx, resolved); err != nil {
+		return err
+	}
+
+	ctx = WithHookVars(ctx, map[string]string{"IMAGE": resolved})
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		ctx, err := withRevisionSpec(ctx, secrets)
+		if err != nil {
+			return err
+		}
+
+		return RunForEachRegion(ctx, waves || opt.Waves, func(ctx context.Context) error {
+			return createRevision(ctx, gc, image, resolved, commitHash)
+		})
+	})
 }
 
-func createRevision(ctx context.Context, gc GCloud, image string, commitHash string) error {
+func createRevision(ctx context.Context, gc GCloud, sourceImage string, image string, commitHash string) error {
 	if err := gc.CreateRevision(ctx, image, commitHash); err != nil {
 		return fmt.Errorf("failed to create revision: %w", err)
 	}
 
+	if err := recordSourceImage(ctx, gc, sourceImage, image); err != nil {
+		return fmt.Errorf("failed to record source image: %w", err)
+	}
+
 	return nil
 }
@@ -3,8 +3,8 @@ package dekopin
 import (
 	"context"
 	"fmt"
+	"os"
 
-	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
@@ -22,7 +22,7 @@ var createTagCmd = &cobra.Command{
 type createTagCommandFlags struct {
 	Tag                 string
 	Revision            string
-	ShouldRemoveTags    bool
+	RemoveTags          TagRemoval
 	ShouldUpdateTraffic bool
 }
 
@@ -43,6 +43,16 @@ func createTagPreRun(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	updateTraffic, err := dekopinCmd.GetUpdateTrafficByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get update traffic flag: %w", err)
+	}
+
+	// Tagging alone serves no users, so only a traffic update is subject to a freeze.
+	if updateTraffic {
+		return checkFreeze(cmd)
+	}
+
 	return nil
 }
 
@@ -52,6 +62,14 @@ func newCreateTagCommandFlags(ctx context.Context, cmd DekopinCommand) (*createT
 		return nil, fmt.Errorf("failed to get tag flag: %w", err)
 	}
 
+	service, hasService := GetServiceConfig(ctx)
+	if hasService && tagFlag == "" {
+		tagFlag = service.Tag
+		if err := ValidateTag(tagFlag); err != nil {
+			return nil, err
+		}
+	}
+
 	tagName, err := CreateRevisionTagName(ctx, tagFlag)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tag name: %w", err)
@@ -62,9 +80,9 @@ func newCreateTagCommandFlags(ctx context.Context, cmd DekopinCommand) (*createT
 		return nil, fmt.Errorf("failed to get revision name: %w", err)
 	}
 
-	removeTags, err := cmd.GetRemoveTagsByFlag()
+	removeTags, err := NewTagRemoval(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get remove tags flag: %w", err)
+		return nil, err
 	}
 
 	updateTraffic, err := cmd.GetUpdateTrafficByFlag()
@@ -72,10 +90,14 @@ func newCreateTagCommandFlags(ctx context.Context, cmd DekopinCommand) (*createT
 		return nil, fmt.Errorf("failed to get update traffic flag: %w", err)
 	}
 
+	if hasService {
+		removeTags.All = removeTags.All || service.RemoveTags
+	}
+
 	return &createTagCommandFlags{
 		Tag:                 tagName,
 		Revision:            revisionName,
-		ShouldRemoveTags:    removeTags,
+		RemoveTags:          removeTags,
 		ShouldUpdateTraffic: updateTraffic,
 	}, nil
 }
@@ -92,12 +114,23 @@ func createTagCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get dekopin command: %w", err)
 	}
 
-	flags, err := newCreateTagCommandFlags(ctx, dekopinCmd)
+	force, err := dekopinCmd.GetForceByFlag()
 	if err != nil {
-		return fmt.Errorf("failed to get create tag command flags: %w", err)
+		return err
 	}
 
-	return createTag(ctx, gc, flags)
+	ctx = SetForce(ctx, force)
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		flags, err := newCreateTagCommandFlags(ctx, dekopinCmd)
+		if err != nil {
+			return fmt.Errorf("failed to get create tag command flags: %w", err)
+		}
+
+		ctx = WithHookVars(ctx, map[string]string{"TAG": flags.Tag, "REVISION": flags.Revision})
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return createTag(ctx, gc, flags)
+		})
+	})
 }
 
 func createTag(ctx context.Context, gc GCloud, flags *createTagCommandFlags) error {
@@ -113,25 +146,14 @@ func createTag(ctx context.Context, gc GCloud, flags *createTagCommandFlags) err
 	}
 
 	if flags.ShouldUpdateTraffic {
-		if err := gc.UpdateTrafficToRevisionTag(ctx, flags.Tag); err != nil {
-			return fmt.Errorf("failed to update traffic to revision tag: %w", err)
-		}
-	}
-
-	if flags.ShouldRemoveTags {
-		activeRevisionTags, err := gc.GetActiveRevisionTags(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get active revision tags: %w", err)
+		if err := smokeCheckTag(ctx, gc, GetHTTPClient(ctx), flags.Tag); err != nil {
+			return err
 		}
 
-		filteredTags := lo.Filter(activeRevisionTags, func(tag string, _ int) bool {
-			return tag != flags.Tag
-		})
-
-		if err := gc.RemoveRevisionTags(ctx, filteredTags); err != nil {
-			return fmt.Errorf("failed to remove revision tags: %w", err)
+		if err := gc.UpdateTrafficToRevisionTag(ctx, flags.Tag); err != nil {
+			return fmt.Errorf("failed to update traffic to revision tag: %w", err)
 		}
 	}
 
-	return nil
+	return removeRevisionTags(ctx, gc, flags.RemoveTags, flags.Tag)
 }
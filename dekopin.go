@@ -13,12 +13,19 @@ import (
 )
 
 const (
+	// TIMEOUT bounds a command in each service and region.
 	TIMEOUT = 120 * time.Second
+
+	// ANNOTATION_OFFLINE marks commands that never call Cloud Run, so they run without credentials.
+	ANNOTATION_OFFLINE = "dekopin/offline"
 )
 
 func Run(ctx context.Context) int {
-	ctx, cancel := context.WithTimeout(ctx, TIMEOUT)
-	defer cancel()
+	ctx = SetHTTPClient(ctx, NewHTTPClient())
+
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil && cmd.Annotations[ANNOTATION_OFFLINE] == "true" {
+		return execute(ctx)
+	}
 
 	sc, err := run.NewServicesClient(ctx)
 	if err != nil {
@@ -36,13 +43,28 @@ func Run(ctx context.Context) int {
 
 	ctx = SetGCloud(ctx, NewGCloud(os.Stdout, os.Stderr, sc, rc))
 
-	if err := rootCmd.ExecuteContext(ctx); err != nil {
+	return execute(ctx)
+}
+
+func execute(ctx context.Context) int {
+	if err := Execute(ctx, os.Args[1:]); err != nil {
 		log.Printf("ERROR: %s", err)
 		return 1
 	}
 	return 0
 }
 
+// Execute runs the command line args with ctx, which carries the GCloud to use.
+func Execute(ctx context.Context, args []string) error {
+	// A command keeps the context of an earlier run, so the one to run is given ctx explicitly.
+	if cmd, _, err := rootCmd.Find(args); err == nil {
+		cmd.SetContext(ctx)
+	}
+
+	rootCmd.SetArgs(args)
+	return rootCmd.ExecuteContext(ctx)
+}
+
 var rootCmd = &cobra.Command{
 	Use:               "dekopin",
 	Short:             "Dekopin is a Cloud Run deployment tool",
@@ -55,43 +77,126 @@ func init() {
 
 	rootCmd.AddCommand(createRevisionCmd)
 	createRevisionCmd.Flags().StringP("image", "i", "", "container image")
-	createRevisionCmd.MarkFlagRequired("image")
+	createRevisionCmd.Flags().StringArray("secret", nil, "secret to expose as ENV_NAME=secret:version or /mount/path=secret:version")
+	createRevisionCmd.Flags().String("override-policy", "", "reason for deploying an image the image policy rejects")
+	createRevisionCmd.Flags().Bool("waves", false, "create the revision in the first region before the remaining regions")
+	markFlagRequired(createRevisionCmd.Flags(), "image")
 
 	rootCmd.AddCommand(createTagCmd)
 	createTagCmd.Flags().StringP("tag", "t", "", "tag name")
 	createTagCmd.Flags().String("revision", CREATE_TAG_DEFAULT_REVISION, "revision name")
 	createTagCmd.Flags().Bool("update-traffic", false, "update traffic to the revision after deploy")
 	createTagCmd.Flags().Bool("remove-tags", false, "remove all revision tags before deploy")
+	createTagCmd.Flags().String("remove-tags-matching", "", "remove only the tags matching this glob, or a regular expression between slashes")
+	createTagCmd.Flags().StringSlice("keep-tags", nil, "comma separated tags or globs never removed")
+	createTagCmd.Flags().Duration("remove-tags-older-than", 0, "remove only the tags whose revision is older than this, e.g. 72h")
+	createTagCmd.Flags().String("emergency", "", "reason for updating traffic during a deploy freeze")
+	createTagCmd.Flags().Bool("force", false, "reassign a protected tag")
 
 	rootCmd.AddCommand(removeTagCmd)
 	removeTagCmd.Flags().StringP("tag", "t", "", "tag name")
-	removeTagCmd.MarkFlagRequired("tag")
+	removeTagCmd.Flags().Bool("force", false, "remove a protected tag")
+	markFlagRequired(removeTagCmd.Flags(), "tag")
 
 	rootCmd.AddCommand(deployCmd)
-	deployCmd.Flags().StringP("image", "i", "", "container image")
-	deployCmd.MarkFlagRequired("image")
+	deployCmd.Flags().StringP("image", "i", "", "container image (defaults to the image of each service in the config)")
 	deployCmd.Flags().StringP("tag", "t", "", "new revision tag name")
 	deployCmd.Flags().Bool("create-tag", false, "create a revision tag after deploy")
 	deployCmd.Flags().Bool("remove-tags", false, "remove all revision tags before deploy")
+	deployCmd.Flags().String("remove-tags-matching", "", "remove only the tags matching this glob, or a regular expression between slashes")
+	deployCmd.Flags().StringSlice("keep-tags", nil, "comma separated tags or globs never removed")
+	deployCmd.Flags().Duration("remove-tags-older-than", 0, "remove only the tags whose revision is older than this, e.g. 72h")
+	deployCmd.Flags().Bool("waves", false, "deploy to the first region before the remaining regions")
+	deployCmd.Flags().StringArray("secret", nil, "secret to expose as ENV_NAME=secret:version or /mount/path=secret:version")
+	deployCmd.Flags().Bool("fail-on-drift", false, "refuse to deploy when the live service has drifted from the config")
+	deployCmd.Flags().String("override-policy", "", "reason for deploying an image the image policy rejects")
+	deployCmd.Flags().String("emergency", "", "reason for deploying during a deploy freeze")
+	deployCmd.Flags().Bool("force", false, "reassign a protected tag")
 
 	rootCmd.AddCommand(srDeployCmd)
 	srDeployCmd.Flags().String("revision", SWITCH_REVISION_DEFAULT_REVISION, "revision name")
+	srDeployCmd.Flags().Bool("watch", false, "watch the health checks after the switch and restore the traffic when they fail")
+	srDeployCmd.Flags().String("emergency", "", "reason for switching traffic during a deploy freeze")
+
+	rootCmd.AddCommand(statusCmd)
+
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().String("from-service", "", "import the settings of an existing Cloud Run service")
+	initCmd.Flags().Bool("force", false, "overwrite an existing config file")
+
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringP("tag", "t", "", "tag expected to exist on the service")
+	diffCmd.Flags().StringP("output", "o", OUTPUT_TEXT, "output format (text, json)")
+
+	rootCmd.AddCommand(bluegreenCmd)
+	bluegreenCmd.AddCommand(bluegreenDeployCmd, bluegreenPromoteCmd, bluegreenAbortCmd)
+	bluegreenDeployCmd.Flags().StringP("image", "i", "", "container image (defaults to the image of each service in the config)")
+	bluegreenDeployCmd.Flags().StringArray("secret", nil, "secret to expose as ENV_NAME=secret:version or /mount/path=secret:version")
+	bluegreenDeployCmd.Flags().String("override-policy", "", "reason for deploying an image the image policy rejects")
+	bluegreenDeployCmd.Flags().Bool("force", false, "reassign a protected green tag")
+	bluegreenPromoteCmd.Flags().String("emergency", "", "reason for promoting during a deploy freeze")
+	bluegreenPromoteCmd.Flags().Bool("force", false, "reassign a protected blue tag")
+
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyInspectCmd)
+	historyCmd.Flags().StringP("output", "o", OUTPUT_TEXT, "output format (text, json)")
+	historyInspectCmd.Flags().String("id", "", "history entry id")
+	historyInspectCmd.Flags().StringP("output", "o", OUTPUT_TEXT, "output format (text, json)")
+	markFlagRequired(historyInspectCmd.Flags(), "id")
+
+	rootCmd.AddCommand(revisionsCmd)
+	revisionsCmd.AddCommand(revisionsDescribeCmd)
+	revisionsDescribeCmd.Flags().String("revision", "", "revision name")
+	markFlagRequired(revisionsDescribeCmd.Flags(), "revision")
+
+	rootCmd.AddCommand(tagsCmd)
+	tagsCmd.AddCommand(tagsListCmd, tagsGCCmd)
+	tagsListCmd.Flags().String("sort", TAG_SORT_TAG, "sort by tag, age (newest first) or traffic (highest first)")
+	tagsListCmd.Flags().String("filter", "", "list only the tags matching this glob, or a regular expression between slashes")
+	tagsListCmd.Flags().Bool("serving", false, "list only the tags whose revision serves traffic")
+	tagsListCmd.Flags().StringP("output", "o", OUTPUT_TEXT, "output format (text, json)")
+	tagsGCCmd.Flags().Duration("ttl", 0, "remove tags whose revision is older than this, e.g. 168h")
+	tagsGCCmd.Flags().String("closed-pattern", "", "remove tags matching this glob whose pull request is closed, e.g. tag-refs-pull-*")
+	tagsGCCmd.Flags().String("scm", SCM_GITHUB, "source control system that tells whether a pull request is closed")
+	tagsGCCmd.Flags().Int("max-count", 0, "keep at most this many tags, removing the oldest first")
+	tagsGCCmd.Flags().Bool("dry-run", false, "print the tags to remove without removing them")
+
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configRenderCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSchemaCmd)
 
 	rootCmd.AddCommand(stDeployCmd)
 	stDeployCmd.Flags().StringP("tag", "t", "", "tag name")
-	stDeployCmd.MarkFlagRequired("tag")
+	markFlagRequired(stDeployCmd.Flags(), "tag")
 	stDeployCmd.Flags().Bool("remove-tags", false, "remove all revision tags except the deployment target revision tag")
+	stDeployCmd.Flags().String("remove-tags-matching", "", "remove only the tags matching this glob, or a regular expression between slashes")
+	stDeployCmd.Flags().StringSlice("keep-tags", nil, "comma separated tags or globs never removed")
+	stDeployCmd.Flags().Duration("remove-tags-older-than", 0, "remove only the tags whose revision is older than this, e.g. 72h")
+	stDeployCmd.Flags().Bool("watch", false, "watch the health checks after the switch and restore the traffic when they fail")
+	stDeployCmd.Flags().String("emergency", "", "reason for switching traffic during a deploy freeze")
 }
 
 func setRootFlags(rootCmd *cobra.Command) {
 	rootCmd.PersistentFlags().String("project", "", "GCP project id")
-	rootCmd.PersistentFlags().String("region", "", "region")
-	rootCmd.PersistentFlags().String("service", "", "service name")
+	rootCmd.PersistentFlags().String("region", "", "region, or a comma separated list of regions")
+	rootCmd.PersistentFlags().String("service", "", "service name, or a comma separated subset of the services in the config")
 	rootCmd.PersistentFlags().String("runner", "", "runner type")
+	rootCmd.PersistentFlags().String("environment", "", "deployment environment, e.g. production")
 	rootCmd.PersistentFlags().StringP("file", "f", "dekopin.yml", "config file name")
+	rootCmd.PersistentFlags().Bool("show-origin", false, "print where each option value came from")
 }
 
 func prepareAllRun(cmd *cobra.Command, args []string) error {
+	origins, err := ApplyEnvToFlags(cmd.Flags(), os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	if err := validateRequiredFlags(cmd.Flags()); err != nil {
+		return err
+	}
+
 	dekopinCmd := NewDekopinCommand(cmd)
 	ctx := SetDekopinCommand(cmd.Context(), dekopinCmd)
 	cmd.SetContext(ctx)
@@ -103,11 +208,12 @@ func prepareAllRun(cmd *cobra.Command, args []string) error {
 	}
 
 	if fileName != "" {
-		config, err = getConfig(fileName)
+		config, err = LoadConfig(fileName)
 		if err != nil {
 			return err
 		}
 	}
+	ctx = SetDekopinConfig(ctx, config)
 
 	cmdOption, err := NewCmdOption(ctx, config, cmd)
 	if err != nil {
@@ -116,9 +222,30 @@ func prepareAllRun(cmd *cobra.Command, args []string) error {
 
 	ctx = SetCmdOption(ctx, cmdOption)
 	cmd.SetContext(ctx)
+
+	showOrigin, err := dekopinCmd.GetShowOriginByFlag()
+	if err != nil {
+		return err
+	}
+	if showOrigin {
+		resolveOptionOrigins(origins, cmdOption, config, fileName)
+		if err := printOrigins(os.Stderr, origins); err != nil {
+			return fmt.Errorf("failed to print option origins: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// prepareOfflineRun only applies environment variables for commands that do not need the command options.
+func prepareOfflineRun(cmd *cobra.Command, args []string) error {
+	if _, err := ApplyEnvToFlags(cmd.Flags(), os.LookupEnv); err != nil {
+		return err
+	}
+
+	return validateRequiredFlags(cmd.Flags())
+}
+
 const (
 	COMMIT_HASH_LENGTH = 7
 )
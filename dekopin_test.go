@@ -2,8 +2,10 @@ package dekopin_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 
+	"cloud.google.com/go/run/apiv2/runpb"
 	"github.com/iwashi623/dekopin"
 	"github.com/stretchr/testify/assert"
 )
@@ -14,6 +16,42 @@ type TestCase[TArgs, TArrangeResult, TActResult any] struct {
 	Assert  func(t *testing.T, assertArgs TArrangeResult, result TActResult)
 }
 
+// fakeGCloud stands in for Cloud Run and records which service and region each call was for.
+// Calls to methods it does not implement panic, as the embedded GCloud is nil.
+type fakeGCloud struct {
+	dekopin.GCloud
+
+	mu    sync.Mutex
+	calls []string
+	// services are keyed by name. A missing service is returned as getServiceErr.
+	services      map[string]*runpb.Service
+	getServiceErr error
+}
+
+func (f *fakeGCloud) record(ctx context.Context, method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	opt, _ := dekopin.GetCmdOption(ctx)
+	f.calls = append(f.calls, method+" "+opt.Service+"/"+opt.Region)
+}
+
+func (f *fakeGCloud) GetService(ctx context.Context) (*runpb.Service, error) {
+	f.record(ctx, "GetService")
+	opt, _ := dekopin.GetCmdOption(ctx)
+	if service, ok := f.services[opt.Service]; ok {
+		return service, nil
+	}
+	if f.getServiceErr != nil {
+		return nil, f.getServiceErr
+	}
+	return &runpb.Service{}, nil
+}
+
+func (f *fakeGCloud) GetRevision(ctx context.Context, revisionName string) (*runpb.Revision, error) {
+	f.record(ctx, "GetRevision")
+	return &runpb.Revision{Name: revisionName}, nil
+}
+
 func TestCreateRevisionTagName(t *testing.T) {
 	type TestResult struct {
 		Tag string
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"time"
 
-	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
 const (
@@ -37,14 +40,47 @@ func deployPreRun(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	return nil
+	image, err := dekopinCmd.GetImageByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get image flag: %w", err)
+	}
+
+	opt, err := GetCmdOption(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if err := checkImagePolicy(cmd, policyImages(opt, image)); err != nil {
+		return err
+	}
+
+	return checkFreeze(cmd)
+}
+
+// policyImages returns the images a command will deploy: the --image flag, or else the image of every service.
+func policyImages(opt *CmdOption, image string) []string {
+	if image != "" {
+		return []string{image}
+	}
+
+	images := []string{}
+	for _, s := range opt.Services {
+		if s.Image != "" {
+			images = append(images, s.Image)
+		}
+	}
+	return images
 }
 
 type DeployCommandFlags struct {
-	Image            string
-	Tag              string
-	ShouldCreateTag  bool
-	ShouldRemoveTags bool
+	Image               string
+	SourceImage         string // Image as given, before it was resolved to a digest
+	Tag                 string
+	ShouldCreateTag     bool
+	RemoveTags          TagRemoval
+	ShouldDeployInWaves bool
+	Secrets             map[string]string
+	ShouldFailOnDrift   bool
 }
 
 func deployCommand(cmd *cobra.Command, args []string) error {
@@ -59,12 +95,16 @@ func deployCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get deploy command flags: %w", err)
 	}
 
-	if flags.Tag == "" && flags.ShouldCreateTag {
-		flags.Tag, err = CreateRevisionTagName(ctx, flags.Tag)
-		if err != nil {
-			return fmt.Errorf("failed to get tag name: %w", err)
-		}
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	force, err := dekopinCmd.GetForceByFlag()
+	if err != nil {
+		return err
 	}
+	ctx = SetForce(ctx, force)
 
 	commitHash, err := GetCommitHash(ctx)
 	if err != nil {
@@ -73,7 +113,125 @@ func deployCommand(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	return deploy(ctx, gc, flags, commitHash)
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	// Every region must get the same revision name, so do not let gcloud generate one per region.
+	if commitHash == "" && len(opt.Regions) > 0 {
+		commitHash = NewRevisionSuffix(time.Now())
+	}
+
+	flags.ShouldDeployInWaves = flags.ShouldDeployInWaves || opt.Waves
+
+	// Nothing is deployed if any service has drifted in any region, so no region is left half done.
+	if flags.ShouldFailOnDrift {
+		if err := CheckDrift(ctx, gc, opt); err != nil {
+			return err
+		}
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		serviceFlags := *flags
+		if service, ok := GetServiceConfig(ctx); ok {
+			serviceFlags.applyServiceConfig(service)
+		}
+
+		if serviceFlags.Image == "" {
+			return fmt.Errorf("image is required")
+		}
+
+		if err := ValidateTag(serviceFlags.Tag); err != nil {
+			return err
+		}
+
+		if serviceFlags.Tag == "" && serviceFlags.ShouldCreateTag {
+			tag, err := CreateRevisionTagName(ctx, serviceFlags.Tag)
+			if err != nil {
+				return fmt.Errorf("failed to get tag name: %w", err)
+			}
+			serviceFlags.Tag = tag
+		}
+
+		ctx, err := withRevisionSpec(ctx, serviceFlags.Secrets)
+		if err != nil {
+			return err
+		}
+
+		// Resolve once so that every region runs the same digest.
+		image, err := ResolveImage(ctx, serviceFlags.Image)
+		if err != nil {
+			return err
+		}
+		serviceFlags.SourceImage, serviceFlags.Image = serviceFlags.Image, image
+
+		if err := VerifyImage(ctx, image); err != nil {
+			return err
+		}
+
+		ctx = WithHookVars(ctx, map[string]string{"TAG": serviceFlags.Tag, "IMAGE": image})
+		return RunForEachRegion(ctx, serviceFlags.ShouldDeployInWaves, func(ctx context.Context) error {
+			return deploy(ctx, gc, &serviceFlags, commitHash)
+		})
+	})
+}
+
+// CheckDrift compares every service in every region with the config and fails if any of them drifted.
+// A service that is not deployed to a region yet has not drifted there.
+func CheckDrift(ctx context.Context, gc GCloud, opt *CmdOption) error {
+	type target struct {
+		ctx context.Context
+		opt *CmdOption
+	}
+
+	targets := []target{{ctx: ctx, opt: opt}}
+	if len(opt.Services) > 0 {
+		targets = []target{}
+		for _, service := range opt.Services {
+			serviceCtx, serviceOpt := serviceContext(ctx, opt, service)
+			targets = append(targets, target{ctx: serviceCtx, opt: serviceOpt})
+		}
+	}
+
+	drifted := false
+	for _, t := range targets {
+		for _, region := range t.opt.TargetRegions() {
+			regionCtx := regionContext(t.ctx, t.opt, region)
+			drift, err := detectServiceDrift(regionCtx, gc, nil)
+			if grpcstatus.Code(err) == codes.NotFound {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to detect drift of %s in %s: %w", t.opt.Service, region, err)
+			}
+			if len(drift) == 0 {
+				continue
+			}
+			drifted = true
+			if err := writeDrift(regionCtx, os.Stderr, drift, OUTPUT_TEXT); err != nil {
+				return err
+			}
+		}
+	}
+
+	if drifted {
+		return fmt.Errorf("the live service has drifted from the config. Run dekopin diff for details")
+	}
+
+	return nil
+}
+
+// applyServiceConfig fills in the values not given by flags from the service's config.
+func (f *DeployCommandFlags) applyServiceConfig(service *ServiceConfig) {
+	if f.Image == "" {
+		f.Image = service.Image
+	}
+	if f.Tag == "" {
+		f.Tag = service.Tag
+	}
+	f.ShouldCreateTag = f.ShouldCreateTag || service.CreateTag
+	f.RemoveTags.All = f.RemoveTags.All || service.RemoveTags
 }
 
 func deploy(
@@ -82,32 +240,30 @@ func deploy(
 	flags *DeployCommandFlags,
 	commitHash string,
 ) error {
-	if err := gc.DeployWithTraffic(ctx, flags.Image, commitHash); err != nil {
-		return fmt.Errorf("failed to deploy to Cloud Run: %w", err)
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
 	}
 
-	if flags.ShouldCreateTag {
-		if err := gc.CreateRevisionTag(ctx, flags.Tag, DEPLOY_DEFAULT_REVISION); err != nil {
-			return fmt.Errorf("failed to create revision tag: %w", err)
+	if opt.Smoke.Enabled() {
+		if err := deployWithSmokeChecks(ctx, gc, GetHTTPClient(ctx), flags, commitHash); err != nil {
+			return err
 		}
+	} else if err := gc.DeployWithTraffic(ctx, flags.Image, commitHash); err != nil {
+		return fmt.Errorf("failed to deploy to Cloud Run: %w", err)
 	}
 
-	if flags.ShouldRemoveTags {
-		activeRevisionTags, err := gc.GetActiveRevisionTags(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get active revision tag: %w", err)
-		}
-
-		filteredTags := lo.Filter(activeRevisionTags, func(tag string, _ int) bool {
-			return tag != flags.Tag
-		})
+	if err := recordSourceImage(ctx, gc, flags.SourceImage, flags.Image); err != nil {
+		return fmt.Errorf("failed to record source image: %w", err)
+	}
 
-		if err := gc.RemoveRevisionTags(ctx, filteredTags); err != nil {
-			return fmt.Errorf("failed to remove revision tags: %w", err)
+	if flags.ShouldCreateTag && !opt.Smoke.Enabled() {
+		if err := gc.CreateRevisionTag(ctx, flags.Tag, DEPLOY_DEFAULT_REVISION); err != nil {
+			return fmt.Errorf("failed to create revision tag: %w", err)
 		}
 	}
 
-	return nil
+	return removeRevisionTags(ctx, gc, flags.RemoveTags, flags.Tag)
 }
 
 func getDeployCommandFlags(cmd *cobra.Command) (*DeployCommandFlags, error) {
@@ -131,15 +287,38 @@ func getDeployCommandFlags(cmd *cobra.Command) (*DeployCommandFlags, error) {
 		return nil, fmt.Errorf("failed to get create-tag flag: %w", err)
 	}
 
-	removeTags, err := dekopinCmd.GetRemoveTagsByFlag()
+	removeTags, err := NewTagRemoval(dekopinCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	waves, err := dekopinCmd.GetWavesByFlag()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waves flag: %w", err)
+	}
+
+	failOnDrift, err := dekopinCmd.GetFailOnDriftByFlag()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fail-on-drift flag: %w", err)
+	}
+
+	secretFlags, err := dekopinCmd.GetSecretsByFlag()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret flag: %w", err)
+	}
+
+	secrets, err := ParseSecretFlags(secretFlags)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get remove-tags flag: %w", err)
+		return nil, err
 	}
 
 	return &DeployCommandFlags{
-		Image:            image,
-		Tag:              tag,
-		ShouldCreateTag:  createTag,
-		ShouldRemoveTags: removeTags,
+		Image:               image,
+		Tag:                 tag,
+		ShouldCreateTag:     createTag,
+		RemoveTags:          removeTags,
+		ShouldDeployInWaves: waves,
+		Secrets:             secrets,
+		ShouldFailOnDrift:   failOnDrift,
 	}, nil
 }
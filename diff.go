@@ -0,0 +1,285 @@
+package dekopin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/spf13/cobra"
+)
+
+const (
+	OUTPUT_TEXT = "text"
+	OUTPUT_JSON = "json"
+)
+
+var diffCmd = &cobra.Command{
+	Use:     "diff",
+	Short:   "Show the drift between dekopin.yml and the live service",
+	PreRunE: diffPreRun,
+	RunE:    diffCommand,
+}
+
+// DriftEntry is one difference between the desired state and the live service.
+type DriftEntry struct {
+	Section string `json:"section"`
+	Field   string `json:"field"`
+	Desired string `json:"desired"`
+	Live    string `json:"live"`
+}
+
+// Label prefixes managed by Google Cloud itself, never drift.
+var systemLabelPrefixes = []string{"cloud.googleapis.com/", "run.googleapis.com/", "serving.knative.dev/", "goog-"}
+
+func diffPreRun(cmd *cobra.Command, args []string) error {
+	dekopinCmd, err := GetDekopinCommand(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	output, err := dekopinCmd.GetOutputByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+
+	if output != OUTPUT_TEXT && output != OUTPUT_JSON {
+		return fmt.Errorf("invalid output format. Valid values: text, json")
+	}
+
+	return nil
+}
+
+func diffCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	tag, err := dekopinCmd.GetTagByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get tag flag: %w", err)
+	}
+
+	output, err := dekopinCmd.GetOutputByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			drift, err := detectServiceDrift(ctx, gc, desiredTags(ctx, tag))
+			if err != nil {
+				return err
+			}
+
+			return writeDrift(ctx, os.Stdout, drift, output)
+		})
+	})
+}
+
+// desiredTags returns the tags that are expected to exist on the service.
+func desiredTags(ctx context.Context, tag string) []string {
+	tags := []string{}
+	if tag != "" {
+		tags = append(tags, tag)
+	}
+	if service, ok := GetServiceConfig(ctx); ok && service.CreateTag && service.Tag != "" && service.Tag != tag {
+		tags = append(tags, service.Tag)
+	}
+	return tags
+}
+
+// detectServiceDrift fetches the live service and its latest ready revision and compares them
+// with the spec of the current service.
+func detectServiceDrift(ctx context.Context, gc GCloud, tags []string) ([]DriftEntry, error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service: %w", err)
+	}
+
+	var revision *runpb.Revision
+	if service.GetLatestReadyRevision() != "" {
+		revision, err = gc.GetRevision(ctx, path.Base(service.GetLatestReadyRevision()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest revision: %w", err)
+		}
+	}
+
+	return DetectDrift(opt.Spec, tags, service, revision), nil
+}
+
+// DetectDrift compares the desired spec and tags with the live service and revision.
+// Only the spec fields that are set are compared. Traffic drifts when it is split, because
+// dekopin always routes all traffic to a single revision.
+func DetectDrift(spec *ServiceSpec, tags []string, service *runpb.Service, revision *runpb.Revision) []DriftEntry {
+	drift := []DriftEntry{}
+	if spec == nil {
+		spec = &ServiceSpec{}
+	}
+
+	var container *runpb.Container
+	if containers := revision.GetContainers(); len(containers) > 0 {
+		container = containers[0]
+	}
+
+	if spec.Env != nil {
+		live := map[string]string{}
+		for _, env := range container.GetEnv() {
+			if env.GetValueSource() == nil {
+				live[env.GetName()] = env.GetValue()
+			}
+		}
+		drift = append(drift, diffMaps("env", spec.Env, live)...)
+	}
+
+	if spec.Secrets != nil {
+		live := map[string]string{}
+		for _, ref := range RevisionSecretRefs(revision.GetContainers(), revision.GetVolumes()) {
+			live[ref.Target] = ref.Secret + ":" + ref.Version
+		}
+		desired := map[string]string{}
+		for target, ref := range spec.Secrets {
+			secret, version := splitSecretRef(ref)
+			desired[target] = secret + ":" + version
+		}
+		drift = append(drift, diffMaps("secrets", desired, live)...)
+	}
+
+	limits := container.GetResources().GetLimits()
+	drift = appendDrift(drift, "resources", "cpu", spec.CPU, limits["cpu"])
+	drift = appendDrift(drift, "resources", "memory", spec.Memory, limits["memory"])
+
+	if spec.Concurrency != nil {
+		drift = appendDrift(drift, "scaling", "concurrency", strconv.Itoa(*spec.Concurrency), strconv.Itoa(int(revision.GetMaxInstanceRequestConcurrency())))
+	}
+	if spec.MinInstances != nil {
+		drift = appendDrift(drift, "scaling", "min_instances", strconv.Itoa(*spec.MinInstances), strconv.Itoa(int(revision.GetScaling().GetMinInstanceCount())))
+	}
+	if spec.MaxInstances != nil {
+		drift = appendDrift(drift, "scaling", "max_instances", strconv.Itoa(*spec.MaxInstances), strconv.Itoa(int(revision.GetScaling().GetMaxInstanceCount())))
+	}
+
+	drift = appendDrift(drift, "service", "service_account", spec.ServiceAccount, revision.GetServiceAccount())
+	if spec.VPCConnector != "" {
+		drift = appendDrift(drift, "service", "vpc_connector", path.Base(spec.VPCConnector), path.Base(revision.GetVpcAccess().GetConnector()))
+	}
+
+	if spec.Labels != nil {
+		live := map[string]string{}
+		for k, v := range service.GetLabels() {
+			if !slices.ContainsFunc(systemLabelPrefixes, func(p string) bool { return strings.HasPrefix(k, p) }) {
+				live[k] = v
+			}
+		}
+		drift = append(drift, diffMaps("labels", spec.Labels, live)...)
+	}
+
+	split := []string{}
+	for _, t := range service.GetTrafficStatuses() {
+		if t.GetPercent() > 0 {
+			revisionName := t.GetRevision()
+			if revisionName == "" {
+				revisionName = "LATEST"
+			}
+			split = append(split, fmt.Sprintf("%s=%d%%", revisionName, t.GetPercent()))
+		}
+	}
+	if len(split) > 1 {
+		drift = append(drift, DriftEntry{Section: "traffic", Field: "split", Desired: "100% to one revision", Live: strings.Join(split, ",")})
+	}
+
+	liveTags := []string{}
+	for _, t := range service.GetTraffic() {
+		if t.GetTag() != "" {
+			liveTags = append(liveTags, t.GetTag())
+		}
+	}
+	for _, tag := range tags {
+		if !slices.Contains(liveTags, tag) {
+			drift = append(drift, DriftEntry{Section: "tags", Field: tag, Desired: "present", Live: "missing"})
+		}
+	}
+
+	return drift
+}
+
+func appendDrift(drift []DriftEntry, section string, field string, desired string, live string) []DriftEntry {
+	if desired == "" || desired == live {
+		return drift
+	}
+	return append(drift, DriftEntry{Section: section, Field: field, Desired: desired, Live: live})
+}
+
+func diffMaps(section string, desired map[string]string, live map[string]string) []DriftEntry {
+	drift := []DriftEntry{}
+	keys := slices.Sorted(maps.Keys(mergeStringMap(desired, live)))
+	for _, k := range keys {
+		d, inDesired := desired[k]
+		l, inLive := live[k]
+		switch {
+		case !inLive:
+			drift = append(drift, DriftEntry{Section: section, Field: k, Desired: d, Live: "(missing)"})
+		case !inDesired:
+			drift = append(drift, DriftEntry{Section: section, Field: k, Desired: "(missing)", Live: l})
+		case d != l:
+			drift = append(drift, DriftEntry{Section: section, Field: k, Desired: d, Live: l})
+		}
+	}
+	return drift
+}
+
+func writeDrift(ctx context.Context, w io.Writer, drift []DriftEntry, output string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if output == OUTPUT_JSON {
+		if err := json.NewEncoder(&buf).Encode(map[string]any{
+			"service": opt.Service,
+			"region":  opt.Region,
+			"drift":   drift,
+		}); err != nil {
+			return fmt.Errorf("failed to write drift: %w", err)
+		}
+	} else {
+		fmt.Fprintf(&buf, "Service: %s (%s)\n", opt.Service, opt.Region)
+		if len(drift) == 0 {
+			fmt.Fprintln(&buf, "No drift")
+		} else {
+			tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "SECTION\tFIELD\tDESIRED\tLIVE")
+			for _, d := range drift {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Section, d.Field, d.Desired, d.Live)
+			}
+			if err := tw.Flush(); err != nil {
+				return fmt.Errorf("failed to write drift: %w", err)
+			}
+		}
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,178 @@
+package dekopin_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/iwashi623/dekopin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDetectDrift(t *testing.T) {
+	type TestResult struct {
+		Drift []dekopin.DriftEntry
+	}
+
+	type ArrangeResult struct {
+		spec     *dekopin.ServiceSpec
+		tags     []string
+		service  *runpb.Service
+		revision *runpb.Revision
+	}
+
+	makeService := func(traffic ...*runpb.TrafficTargetStatus) *runpb.Service {
+		return &runpb.Service{
+			Labels:          map[string]string{"team": "backend", "goog-managed-by": "cloudrun"},
+			TrafficStatuses: traffic,
+			Traffic:         []*runpb.TrafficTarget{{Tag: "stable"}},
+		}
+	}
+
+	makeRevision := func() *runpb.Revision {
+		return &runpb.Revision{
+			Containers: []*runpb.Container{{
+				Env: []*runpb.EnvVar{
+					{Name: "APP_ENV", Values: &runpb.EnvVar_Value{Value: "production"}},
+					{Name: "LOG_LEVEL", Values: &runpb.EnvVar_Value{Value: "debug"}},
+				},
+				Resources: &runpb.ResourceRequirements{Limits: map[string]string{"cpu": "1", "memory": "512Mi"}},
+			}},
+			Scaling:                       &runpb.RevisionScaling{MinInstanceCount: 0, MaxInstanceCount: 10},
+			MaxInstanceRequestConcurrency: 80,
+		}
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_no_drift_when_live_matches_the_spec": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					spec: &dekopin.ServiceSpec{
+						Env:          map[string]string{"APP_ENV": "production", "LOG_LEVEL": "debug"},
+						CPU:          "1",
+						Memory:       "512Mi",
+						Concurrency:  lo.ToPtr(80),
+						MaxInstances: lo.ToPtr(10),
+						Labels:       map[string]string{"team": "backend"},
+					},
+					tags:     []string{"stable"},
+					service:  makeService(&runpb.TrafficTargetStatus{Percent: 100}),
+					revision: makeRevision(),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Empty(t, result.Drift)
+			},
+		},
+		"success_reports_env_resources_scaling_traffic_and_tags": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					spec: &dekopin.ServiceSpec{
+						Env:          map[string]string{"APP_ENV": "production"},
+						Memory:       "1Gi",
+						MaxInstances: lo.ToPtr(5),
+					},
+					tags: []string{"qa"},
+					service: makeService(
+						&runpb.TrafficTargetStatus{Revision: "svc-a", Percent: 90},
+						&runpb.TrafficTargetStatus{Revision: "svc-b", Percent: 10},
+					),
+					revision: makeRevision(),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, []dekopin.DriftEntry{
+					{Section: "env", Field: "LOG_LEVEL", Desired: "(missing)", Live: "debug"},
+					{Section: "resources", Field: "memory", Desired: "1Gi", Live: "512Mi"},
+					{Section: "scaling", Field: "max_instances", Desired: "5", Live: "10"},
+					{Section: "traffic", Field: "split", Desired: "100% to one revision", Live: "svc-a=90%,svc-b=10%"},
+					{Section: "tags", Field: "qa", Desired: "present", Live: "missing"},
+				}, result.Drift)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Drift: dekopin.DetectDrift(ar.spec, ar.tags, ar.service, ar.revision),
+			})
+		})
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	type TestResult struct {
+		Calls []string
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		gc *fakeGCloud
+	}
+
+	split := &runpb.Service{
+		TrafficStatuses: []*runpb.TrafficTargetStatus{
+			{Revision: "svc-a", Percent: 90},
+			{Revision: "svc-b", Percent: 10},
+		},
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_a_service_not_deployed_to_a_region_has_not_drifted": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					gc: &fakeGCloud{getServiceErr: status.Error(codes.NotFound, "service not found")},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"GetService test-service/asia-northeast1", "GetService test-service/us-central1"}, result.Calls)
+			},
+		},
+		"error_a_drifted_service_fails": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					gc: &fakeGCloud{services: map[string]*runpb.Service{"test-service": split}},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "the live service has drifted from the config")
+			},
+		},
+		"error_other_errors_getting_the_service_fail": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					gc: &fakeGCloud{getServiceErr: errors.New("permission denied")},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "failed to detect drift of test-service in asia-northeast1")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+
+			opt := &dekopin.CmdOption{
+				Project: "test-project",
+				Service: "test-service",
+				Runner:  dekopin.RUNNER_LOCAL,
+				Regions: []string{"asia-northeast1", "us-central1"},
+			}
+			err := dekopin.CheckDrift(dekopin.SetCmdOption(context.Background(), opt), ar.gc, opt)
+
+			c.Assert(t, ar, TestResult{
+				Calls: ar.gc.calls,
+				Err:   err,
+			})
+		})
+	}
+}
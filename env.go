@@ -0,0 +1,147 @@
+package dekopin
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	ENV_PREFIX = "DEKOPIN_"
+
+	ORIGIN_FLAG    = "flag"
+	ORIGIN_ENV     = "env"
+	ORIGIN_FILE    = "file"
+	ORIGIN_DEFAULT = "default"
+
+	ANNOTATION_REQUIRED = "dekopin/required"
+)
+
+// markFlagRequired marks a flag as required. Unlike cobra's MarkFlagRequired the requirement
+// is checked after environment variables are applied, so DEKOPIN_ variables satisfy it too.
+func markFlagRequired(flags *pflag.FlagSet, name string) {
+	if err := flags.SetAnnotation(name, ANNOTATION_REQUIRED, []string{"true"}); err != nil {
+		panic(err)
+	}
+}
+
+func validateRequiredFlags(flags *pflag.FlagSet) error {
+	missing := []string{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		if _, ok := f.Annotations[ANNOTATION_REQUIRED]; ok && !f.Changed {
+			missing = append(missing, f.Name)
+		}
+	})
+
+	if len(missing) > 0 {
+		return fmt.Errorf(`required flag(s) "%s" not set`, strings.Join(missing, `", "`))
+	}
+	return nil
+}
+
+// FlagEnvName returns the environment variable that overrides a flag, e.g. DEKOPIN_REMOVE_TAGS for --remove-tags.
+func FlagEnvName(flagName string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Origin tells where a resolved option value came from.
+type Origin struct {
+	Name   string
+	Value  string
+	Source string
+	From   string
+}
+
+// ApplyEnvToFlags sets every flag that was not given on the command line from its
+// DEKOPIN_ environment variable, so that flags take precedence over the environment.
+// It returns the origin of every flag value.
+func ApplyEnvToFlags(flags *pflag.FlagSet, lookupEnv func(string) (string, bool)) (map[string]Origin, error) {
+	origins := map[string]Origin{}
+	var applyErr error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if applyErr != nil || f.Name == "help" {
+			return
+		}
+
+		if f.Changed {
+			origins[f.Name] = Origin{Name: f.Name, Value: f.Value.String(), Source: ORIGIN_FLAG, From: "--" + f.Name}
+			return
+		}
+
+		envName := FlagEnvName(f.Name)
+		value, ok := lookupEnv(envName)
+		if !ok {
+			origins[f.Name] = Origin{Name: f.Name, Value: f.Value.String(), Source: ORIGIN_DEFAULT}
+			return
+		}
+
+		if err := flags.Set(f.Name, value); err != nil {
+			applyErr = fmt.Errorf("invalid value for %s: %w", envName, err)
+			return
+		}
+		origins[f.Name] = Origin{Name: f.Name, Value: f.Value.String(), Source: ORIGIN_ENV, From: envName}
+	})
+	if applyErr != nil {
+		return nil, applyErr
+	}
+
+	return origins, nil
+}
+
+// resolveOptionOrigins records the global options that were filled in from the configuration file.
+func resolveOptionOrigins(origins map[string]Origin, opt *CmdOption, config *DekopinConfig, fileName string) {
+	resolved := map[string]string{
+		"project": opt.Project,
+		"region":  strings.Join(opt.TargetRegions(), ","),
+		"service": opt.Service,
+		"runner":  opt.Runner,
+
+		"environment": opt.Environment,
+	}
+	if len(opt.Services) > 0 {
+		resolved["service"] = strings.Join(serviceNames(opt.Services), ",")
+	}
+
+	for name, value := range resolved {
+		origin := origins[name]
+		if origin.Source == ORIGIN_DEFAULT && config != nil && value != "" {
+			origin = Origin{Name: name, Source: ORIGIN_FILE, From: fileName}
+		}
+		origin.Name = name
+		origin.Value = value
+		origins[name] = origin
+	}
+}
+
+func serviceNames(services []ServiceConfig) []string {
+	names := make([]string, 0, len(services))
+	for _, s := range services {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func printOrigins(w io.Writer, origins map[string]Origin) error {
+	names := make([]string, 0, len(origins))
+	for name := range origins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPTION\tVALUE\tORIGIN")
+	for _, name := range names {
+		o := origins[name]
+		origin := o.Source
+		if o.From != "" {
+			origin += " " + o.From
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", o.Name, o.Value, origin)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,94 @@
+package dekopin_test
+
+import (
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyEnvToFlags(t *testing.T) {
+	type TestResult struct {
+		Cmd     *cobra.Command
+		Origins map[string]dekopin.Origin
+		Err     error
+	}
+
+	type ArrangeResult struct {
+		cmd *cobra.Command
+		env map[string]string
+	}
+
+	makeCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("project", "", "")
+		cmd.Flags().String("region", "", "")
+		cmd.Flags().StringP("file", "f", "dekopin.yml", "")
+		cmd.Flags().Bool("remove-tags", false, "")
+		if err := cmd.Flags().Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_flag_takes_precedence_over_env_and_env_over_default": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					cmd: makeCmd("--project", "flag-project"),
+					env: map[string]string{
+						"DEKOPIN_PROJECT":     "env-project",
+						"DEKOPIN_REGION":      "env-region",
+						"DEKOPIN_REMOVE_TAGS": "true",
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+
+				project, _ := result.Cmd.Flags().GetString("project")
+				region, _ := result.Cmd.Flags().GetString("region")
+				file, _ := result.Cmd.Flags().GetString("file")
+				removeTags, _ := result.Cmd.Flags().GetBool("remove-tags")
+				assert.Equal(t, "flag-project", project)
+				assert.Equal(t, "env-region", region)
+				assert.Equal(t, "dekopin.yml", file)
+				assert.True(t, removeTags)
+
+				assert.Equal(t, dekopin.ORIGIN_FLAG, result.Origins["project"].Source)
+				assert.Equal(t, dekopin.ORIGIN_ENV, result.Origins["region"].Source)
+				assert.Equal(t, "DEKOPIN_REGION", result.Origins["region"].From)
+				assert.Equal(t, dekopin.ORIGIN_DEFAULT, result.Origins["file"].Source)
+			},
+		},
+		"error_invalid_env_value": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					cmd: makeCmd(),
+					env: map[string]string{
+						"DEKOPIN_REMOVE_TAGS": "maybe",
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "DEKOPIN_REMOVE_TAGS")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			origins, err := dekopin.ApplyEnvToFlags(ar.cmd.Flags(), func(key string) (string, bool) {
+				v, ok := ar.env[key]
+				return v, ok
+			})
+			c.Assert(t, ar, TestResult{
+				Cmd:     ar.cmd,
+				Origins: origins,
+				Err:     err,
+			})
+		})
+	}
+}
@@ -3,6 +3,8 @@ package dekopin
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -33,6 +35,30 @@ type DekopinCommand interface {
 	GetCreateTagByFlag() (bool, error)
 	GetRemoveTagsByFlag() (bool, error)
 	GetUpdateTrafficByFlag() (bool, error)
+	GetWavesByFlag() (bool, error)
+	GetShowOriginByFlag() (bool, error)
+	GetEnvironmentByFlag() (string, error)
+	GetSecretsByFlag() ([]string, error)
+	GetOutputByFlag() (string, error)
+	GetFailOnDriftByFlag() (bool, error)
+	GetFromServiceByFlag() (string, error)
+	GetForceByFlag() (bool, error)
+	GetOverridePolicyByFlag() (string, error)
+	GetWatchByFlag() (bool, error)
+	GetEmergencyByFlag() (string, error)
+	GetIDByFlag() (string, error)
+	GetTTLByFlag() (time.Duration, error)
+	GetClosedPatternByFlag() (string, error)
+	GetSCMByFlag() (string, error)
+	GetMaxCountByFlag() (int, error)
+	GetDryRunByFlag() (bool, error)
+	GetRemoveTagsMatchingByFlag() (string, error)
+	GetKeepTagsByFlag() ([]string, error)
+	GetRemoveTagsOlderThanByFlag() (time.Duration, error)
+	GetSortByFlag() (string, error)
+	GetFilterByFlag() (string, error)
+	GetServingByFlag() (bool, error)
+	CommandName() string
 }
 
 type dekopinCommand struct {
@@ -137,3 +163,201 @@ func (c *dekopinCommand) GetUpdateTrafficByFlag() (bool, error) {
 	}
 	return updateTraffic, nil
 }
+
+func (c *dekopinCommand) GetWavesByFlag() (bool, error) {
+	waves, err := c.Flags().GetBool("waves")
+	if err != nil {
+		return false, fmt.Errorf("failed to get waves flag: %w", err)
+	}
+	return waves, nil
+}
+
+func (c *dekopinCommand) GetShowOriginByFlag() (bool, error) {
+	showOrigin, err := c.Flags().GetBool("show-origin")
+	if err != nil {
+		return false, fmt.Errorf("failed to get show-origin flag: %w", err)
+	}
+	return showOrigin, nil
+}
+
+// GetEnvironmentByFlag returns an empty string when the command has no environment flag.
+func (c *dekopinCommand) GetEnvironmentByFlag() (string, error) {
+	if c.Flags().Lookup("environment") == nil {
+		return "", nil
+	}
+
+	environment, err := c.Flags().GetString("environment")
+	if err != nil {
+		return "", fmt.Errorf("failed to get environment flag: %w", err)
+	}
+	return environment, nil
+}
+
+func (c *dekopinCommand) GetSecretsByFlag() ([]string, error) {
+	secrets, err := c.Flags().GetStringArray("secret")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret flag: %w", err)
+	}
+	return secrets, nil
+}
+
+func (c *dekopinCommand) GetOutputByFlag() (string, error) {
+	output, err := c.Flags().GetString("output")
+	if err != nil {
+		return "", fmt.Errorf("failed to get output flag: %w", err)
+	}
+	return output, nil
+}
+
+func (c *dekopinCommand) GetFailOnDriftByFlag() (bool, error) {
+	failOnDrift, err := c.Flags().GetBool("fail-on-drift")
+	if err != nil {
+		return false, fmt.Errorf("failed to get fail-on-drift flag: %w", err)
+	}
+	return failOnDrift, nil
+}
+
+func (c *dekopinCommand) GetFromServiceByFlag() (string, error) {
+	fromService, err := c.Flags().GetString("from-service")
+	if err != nil {
+		return "", fmt.Errorf("failed to get from-service flag: %w", err)
+	}
+	return fromService, nil
+}
+
+func (c *dekopinCommand) GetForceByFlag() (bool, error) {
+	force, err := c.Flags().GetBool("force")
+	if err != nil {
+		return false, fmt.Errorf("failed to get force flag: %w", err)
+	}
+	return force, nil
+}
+
+func (c *dekopinCommand) GetOverridePolicyByFlag() (string, error) {
+	reason, err := c.Flags().GetString("override-policy")
+	if err != nil {
+		return "", fmt.Errorf("failed to get override-policy flag: %w", err)
+	}
+	return reason, nil
+}
+
+func (c *dekopinCommand) GetWatchByFlag() (bool, error) {
+	watch, err := c.Flags().GetBool("watch")
+	if err != nil {
+		return false, fmt.Errorf("failed to get watch flag: %w", err)
+	}
+	return watch, nil
+}
+
+func (c *dekopinCommand) GetEmergencyByFlag() (string, error) {
+	reason, err := c.Flags().GetString("emergency")
+	if err != nil {
+		return "", fmt.Errorf("failed to get emergency flag: %w", err)
+	}
+	return reason, nil
+}
+
+func (c *dekopinCommand) GetIDByFlag() (string, error) {
+	id, err := c.Flags().GetString("id")
+	if err != nil {
+		return "", fmt.Errorf("failed to get id flag: %w", err)
+	}
+	return id, nil
+}
+
+func (c *dekopinCommand) GetTTLByFlag() (time.Duration, error) {
+	ttl, err := c.Flags().GetDuration("ttl")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get ttl flag: %w", err)
+	}
+	return ttl, nil
+}
+
+func (c *dekopinCommand) GetClosedPatternByFlag() (string, error) {
+	pattern, err := c.Flags().GetString("closed-pattern")
+	if err != nil {
+		return "", fmt.Errorf("failed to get closed-pattern flag: %w", err)
+	}
+	return pattern, nil
+}
+
+func (c *dekopinCommand) GetSCMByFlag() (string, error) {
+	scm, err := c.Flags().GetString("scm")
+	if err != nil {
+		return "", fmt.Errorf("failed to get scm flag: %w", err)
+	}
+	return scm, nil
+}
+
+func (c *dekopinCommand) GetMaxCountByFlag() (int, error) {
+	maxCount, err := c.Flags().GetInt("max-count")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get max-count flag: %w", err)
+	}
+	return maxCount, nil
+}
+
+func (c *dekopinCommand) GetDryRunByFlag() (bool, error) {
+	dryRun, err := c.Flags().GetBool("dry-run")
+	if err != nil {
+		return false, fmt.Errorf("failed to get dry-run flag: %w", err)
+	}
+	return dryRun, nil
+}
+
+func (c *dekopinCommand) GetRemoveTagsMatchingByFlag() (string, error) {
+	matching, err := c.Flags().GetString("remove-tags-matching")
+	if err != nil {
+		return "", fmt.Errorf("failed to get remove-tags-matching flag: %w", err)
+	}
+	return matching, nil
+}
+
+func (c *dekopinCommand) GetKeepTagsByFlag() ([]string, error) {
+	keep, err := c.Flags().GetStringSlice("keep-tags")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keep-tags flag: %w", err)
+	}
+	return keep, nil
+}
+
+func (c *dekopinCommand) GetRemoveTagsOlderThanByFlag() (time.Duration, error) {
+	olderThan, err := c.Flags().GetDuration("remove-tags-older-than")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get remove-tags-older-than flag: %w", err)
+	}
+	return olderThan, nil
+}
+
+func (c *dekopinCommand) GetSortByFlag() (string, error) {
+	sortBy, err := c.Flags().GetString("sort")
+	if err != nil {
+		return "", fmt.Errorf("failed to get sort flag: %w", err)
+	}
+	return sortBy, nil
+}
+
+func (c *dekopinCommand) GetFilterByFlag() (string, error) {
+	filter, err := c.Flags().GetString("filter")
+	if err != nil {
+		return "", fmt.Errorf("failed to get filter flag: %w", err)
+	}
+	return filter, nil
+}
+
+func (c *dekopinCommand) GetServingByFlag() (bool, error) {
+	serving, err := c.Flags().GetBool("serving")
+	if err != nil {
+		return false, fmt.Errorf("failed to get serving flag: %w", err)
+	}
+	return serving, nil
+}
+
+// CommandName returns the command path below the root command, e.g. bluegreen promote.
+func (c *dekopinCommand) CommandName() string {
+	name := c.CommandPath()
+	if c.HasParent() {
+		name = strings.TrimPrefix(name, c.Root().Name()+" ")
+	}
+	return name
+}
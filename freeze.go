@@ -0,0 +1,276 @@
+package dekopin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	FREEZE_DATE_FORMAT = "2006-01-02"
+)
+
+// FreezeWindow forbids traffic changes while it is active. A window is either a date range
+// (from/to) or a cron expression matching every frozen minute, e.g. "* * * * 0,6" for weekends.
+type FreezeWindow struct {
+	Name         string   `yaml:"name"`
+	Environments []string `yaml:"environments,omitempty"` // Empty means every environment
+	From         string   `yaml:"from,omitempty"`         // RFC 3339 time or YYYY-MM-DD
+	To           string   `yaml:"to,omitempty"`           // RFC 3339 time or YYYY-MM-DD, inclusive
+	Cron         string   `yaml:"cron,omitempty"`
+	Timezone     string   `yaml:"timezone,omitempty"` // IANA name for dates and cron, default UTC
+}
+
+func validateFreezeWindows(windows []FreezeWindow) error {
+	errs := []error{}
+	for i, w := range windows {
+		if w.Name == "" {
+			errs = append(errs, fmt.Errorf("freeze[%d]: name is required", i))
+		}
+		if (w.Cron == "") == (w.From == "" && w.To == "") {
+			errs = append(errs, fmt.Errorf("freeze[%d]: set either cron or from/to", i))
+			continue
+		}
+		if _, err := w.Active(time.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("freeze[%d]: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Active reports whether the window covers now.
+func (w FreezeWindow) Active(now time.Time) (bool, error) {
+	loc := time.UTC
+	if w.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false, fmt.Errorf("invalid timezone %q: %w", w.Timezone, err)
+		}
+	}
+	now = now.In(loc)
+
+	if w.Cron != "" {
+		cron, err := ParseCron(w.Cron)
+		if err != nil {
+			return false, err
+		}
+		return cron.Match(now), nil
+	}
+
+	if w.From != "" {
+		from, _, err := parseFreezeTime(w.From, loc)
+		if err != nil {
+			return false, err
+		}
+		if now.Before(from) {
+			return false, nil
+		}
+	}
+
+	if w.To != "" {
+		to, dateOnly, err := parseFreezeTime(w.To, loc)
+		if err != nil {
+			return false, err
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		if !now.Before(to) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// parseFreezeTime parses an RFC 3339 time or a date, which starts at midnight in loc.
+func parseFreezeTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(FREEZE_DATE_FORMAT, value, loc); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q. Expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, false, nil
+}
+
+// ActiveFreeze returns the first window of the environment that covers now.
+func ActiveFreeze(windows []FreezeWindow, environment string, now time.Time) (*FreezeWindow, error) {
+	for _, w := range windows {
+		if len(w.Environments) > 0 && !slices.Contains(w.Environments, environment) {
+			continue
+		}
+
+		active, err := w.Active(now)
+		if err != nil {
+			return nil, fmt.Errorf("freeze %s: %w", w.Name, err)
+		}
+		if active {
+			return &w, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Cron is a parsed five field cron expression: minute, hour, day of month, month and day of week.
+type Cron struct {
+	fields [5][]bool
+	// Like cron, a time matches either day field when both are restricted.
+	domRestricted bool
+	dowRestricted bool
+}
+
+var cronRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+func ParseCron(expr string) (*Cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid cron %q: expected 5 fields", expr)
+	}
+
+	cron := &Cron{}
+	for i, part := range parts {
+		field, err := parseCronField(part, cronRanges[i][0], cronRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron %q: %w", expr, err)
+		}
+		cron.fields[i] = field
+	}
+
+	// Sunday is both 0 and 7.
+	cron.fields[4][0] = cron.fields[4][0] || cron.fields[4][7]
+	cron.domRestricted = parts[2] != "*"
+	cron.dowRestricted = parts[4] != "*"
+
+	return cron, nil
+}
+
+func parseCronField(field string, lo int, hi int) ([]bool, error) {
+	values := make([]bool, hi+1)
+	for _, item := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepText)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", item)
+			}
+		}
+
+		start, end := lo, hi
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(first); err != nil {
+				return nil, fmt.Errorf("invalid value %q", item)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(last); err != nil {
+					return nil, fmt.Errorf("invalid value %q", item)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+
+		if start < lo || end > hi || start > end {
+			return nil, fmt.Errorf("value %q out of range %d-%d", item, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// Match reports whether t, to the minute, matches the expression.
+func (c *Cron) Match(t time.Time) bool {
+	if !c.fields[0][t.Minute()] || !c.fields[1][t.Hour()] || !c.fields[3][int(t.Month())] {
+		return false
+	}
+
+	dom := c.fields[2][t.Day()]
+	dow := c.fields[4][int(t.Weekday())]
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+type emergencyKey struct{}
+
+func SetEmergencyOverride(ctx context.Context, override *PolicyOverride) context.Context {
+	return context.WithValue(ctx, emergencyKey{}, override)
+}
+
+func GetEmergencyOverride(ctx context.Context) (*PolicyOverride, bool) {
+	override, ok := ctx.Value(emergencyKey{}).(*PolicyOverride)
+	return override, ok
+}
+
+// checkFreeze is called from the pre-run of the commands that change traffic. During a freeze
+// they fail unless --emergency gives a reason, which is logged and recorded in the history.
+func checkFreeze(cmd *cobra.Command) error {
+	ctx := cmd.Context()
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	reason, err := dekopinCmd.GetEmergencyByFlag()
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("emergency") && strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("--emergency requires a reason")
+	}
+
+	window, err := ActiveFreeze(opt.Freeze, opt.Environment, time.Now())
+	if err != nil {
+		return err
+	}
+	if window == nil {
+		return nil
+	}
+
+	if reason == "" {
+		return fmt.Errorf("traffic changes are frozen by %s. Pass --emergency \"<reason>\" to deploy anyway", window.Name)
+	}
+
+	override := &PolicyOverride{
+		Reason:      reason,
+		Actor:       policyActor(),
+		Environment: opt.Environment,
+		Violations:  []string{"freeze: " + window.Name},
+		Time:        time.Now().UTC(),
+	}
+	record, err := json.Marshal(override)
+	if err != nil {
+		return fmt.Errorf("failed to encode emergency override: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "AUDIT: freeze %s overridden: %s\n", window.Name, record)
+
+	cmd.SetContext(SetEmergencyOverride(ctx, override))
+	return nil
+}
@@ -0,0 +1,195 @@
+package dekopin_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveFreeze(t *testing.T) {
+	type TestResult struct {
+		Window *dekopin.FreezeWindow
+		Err    error
+	}
+
+	type ArrangeResult struct {
+		windows     []dekopin.FreezeWindow
+		environment string
+		now         time.Time
+	}
+
+	yearEnd := dekopin.FreezeWindow{Name: "year-end", Environments: []string{"production"}, From: "2025-12-27", To: "2026-01-04", Timezone: "Asia/Tokyo"}
+	// Friday 18:00 to Monday 00:00 in Tokyo.
+	weekend := dekopin.FreezeWindow{Name: "weekend", Cron: "* 18-23 * * 5", Timezone: "Asia/Tokyo"}
+	saturday := dekopin.FreezeWindow{Name: "saturday", Cron: "* * * * 6,7"}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_inside_the_date_range": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					windows:     []dekopin.FreezeWindow{yearEnd},
+					environment: "production",
+					now:         time.Date(2026, 1, 4, 14, 0, 0, 0, time.UTC), // 23:00 in Tokyo, the last day
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, &yearEnd, result.Window)
+			},
+		},
+		"success_after_the_date_range": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					windows:     []dekopin.FreezeWindow{yearEnd},
+					environment: "production",
+					now:         time.Date(2026, 1, 4, 15, 0, 0, 0, time.UTC), // midnight in Tokyo
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Nil(t, result.Window)
+			},
+		},
+		"success_other_environment_is_not_frozen": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					windows:     []dekopin.FreezeWindow{yearEnd},
+					environment: "staging",
+					now:         time.Date(2025, 12, 30, 0, 0, 0, 0, time.UTC),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Nil(t, result.Window)
+			},
+		},
+		"success_cron_in_timezone": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					windows: []dekopin.FreezeWindow{yearEnd, weekend},
+					now:     time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC), // Friday 19:30 in Tokyo
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, &weekend, result.Window)
+			},
+		},
+		"success_cron_outside_hours": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					windows: []dekopin.FreezeWindow{weekend},
+					now:     time.Date(2026, 10, 16, 8, 59, 0, 0, time.UTC), // Friday 17:59 in Tokyo
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Nil(t, result.Window)
+			},
+		},
+		"success_cron_sunday_as_seven": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					windows: []dekopin.FreezeWindow{saturday},
+					now:     time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), // Sunday
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, &saturday, result.Window)
+			},
+		},
+		"error_invalid_cron": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					windows: []dekopin.FreezeWindow{{Name: "broken", Cron: "* 25 * * *"}},
+					now:     time.Now(),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "freeze broken")
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			window, err := dekopin.ActiveFreeze(args.windows, args.environment, args.now)
+			tc.Assert(t, args, TestResult{Window: window, Err: err})
+		})
+	}
+}
+
+func TestCronMatch(t *testing.T) {
+	type TestResult struct {
+		Match bool
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		expr string
+		now  time.Time
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_step_matches": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{expr: "*/15 9-17 * * 1-5", now: time.Date(2026, 10, 19, 9, 45, 0, 0, time.UTC)}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.True(t, result.Match)
+			},
+		},
+		"success_step_does_not_match": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{expr: "*/15 9-17 * * 1-5", now: time.Date(2026, 10, 19, 9, 46, 0, 0, time.UTC)}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.False(t, result.Match)
+			},
+		},
+		"success_restricted_day_fields_match_either": {
+			Arrange: func() ArrangeResult {
+				// The 1st of the month, which is a Sunday.
+				return ArrangeResult{expr: "* * 1 * 5", now: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.True(t, result.Match)
+			},
+		},
+		"error_wrong_field_count": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{expr: "* * * *"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "expected 5 fields")
+			},
+		},
+		"error_invalid_step": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{expr: "*/0 * * * *"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "invalid step")
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			cron, err := dekopin.ParseCron(args.expr)
+			result := TestResult{Err: err}
+			if err == nil {
+				result.Match = cron.Match(args.now)
+			}
+			tc.Assert(t, args, result)
+		})
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
+	"maps"
 	"os/exec"
+	"path"
+	"slices"
+	"strings"
 
 	run "cloud.google.com/go/run/apiv2"
 	"cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 type gcloudKey struct{}
@@ -26,17 +32,24 @@ func GetGCloud(ctx context.Context) (GCloud, error) {
 }
 
 type GCloud interface {
-	CreateRevision(ctx context.Context, imageName string, commitHash string) error          // Create a revision
-	CreateRevisionTag(ctx context.Context, revisionTag string, revisionName string) error   // Assign a tag to a revision
-	RemoveRevisionTag(ctx context.Context, revisionTag string) error                        // Remove a tag from a revision
-	RemoveRevisionTags(ctx context.Context, revisionTags []string) error                    // Remove tags from a revision
-	Deploy(ctx context.Context, imageName string, commitHash string, useTraffic bool) error // Deploy a revision
-	UpdateTrafficToLatestRevision(ctx context.Context) error                                // Update traffic to the latest revision
-	UpdateTrafficToRevision(ctx context.Context, revisionName string) error                 // Update traffic to the specified revision
-	UpdateTrafficToRevisionTag(ctx context.Context, tag string) error                       // Update traffic to the specified tag
-	DeployWithTraffic(ctx context.Context, imageName string, commitHash string) error       // Deploy with traffic
-	GetActiveRevisionTags(ctx context.Context) ([]string, error)                            // Get active revision tags
-	GetRevision(ctx context.Context, revisionName string) (*runpb.Revision, error)          // Get a revision
+	CreateRevision(ctx context.Context, imageName string, commitHash string) error                         // Create a revision
+	CreateRevisionTag(ctx context.Context, revisionTag string, revisionName string) error                  // Assign a tag to a revision
+	RemoveRevisionTag(ctx context.Context, revisionTag string) error                                       // Remove a tag from a revision
+	RemoveRevisionTags(ctx context.Context, revisionTags []string) error                                   // Remove tags from a revision
+	Deploy(ctx context.Context, imageName string, commitHash string, useTraffic bool) error                // Deploy a revision
+	UpdateTrafficToLatestRevision(ctx context.Context) error                                               // Update traffic to the latest revision
+	UpdateTrafficToRevision(ctx context.Context, revisionName string) error                                // Update traffic to the specified revision
+	UpdateTrafficToRevisionTag(ctx context.Context, tag string) error                                      // Update traffic to the specified tag
+	DeployWithTraffic(ctx context.Context, imageName string, commitHash string) error                      // Deploy with traffic
+	GetActiveRevisionTags(ctx context.Context) ([]string, error)                                           // Get active revision tags
+	ListTags(ctx context.Context) ([]TagRecord, error)                                                     // Get the tags with their revisions
+	GetRevision(ctx context.Context, revisionName string) (*runpb.Revision, error)                         // Get a revision
+	GetService(ctx context.Context) (*runpb.Service, error)                                                // Get the service
+	ListRevisions(ctx context.Context) ([]string, error)                                                   // Get the names of the service's revisions
+	UpdateServiceAnnotations(ctx context.Context, annotations map[string]string) error                     // Set annotations on the service, removing those set to ""
+	UpdateServiceAnnotationsIfMatch(ctx context.Context, etag string, annotations map[string]string) error // Set annotations unless the service changed since etag, failing with codes.Aborted
+	UpdateTrafficSplit(ctx context.Context, split map[string]int32) error                                  // Split traffic between revisions
+	UpdateRevisionTags(ctx context.Context, tags map[string]string) error                                  // Assign tags to revisions at once
 }
 
 type gcloud struct {
@@ -80,8 +93,7 @@ func (c *gcloud) GetRevision(ctx context.Context, revisionName string) (*runpb.R
 	return revision, nil
 }
 
-func (c *gcloud) GetActiveRevisionTags(ctx context.Context) ([]string, error) {
-	tagNames := []string{}
+func (c *gcloud) GetService(ctx context.Context) (*runpb.Service, error) {
 	opt, err := GetCmdOption(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cmdOption: %w", err)
@@ -94,6 +106,78 @@ func (c *gcloud) GetActiveRevisionTags(ctx context.Context) ([]string, error) {
 		return nil, fmt.Errorf("failed to get service: %w", err)
 	}
 
+	return service, nil
+}
+
+func (c *gcloud) ListRevisions(ctx context.Context) ([]string, error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	names := []string{}
+	it := c.RevisionsClient.ListRevisions(ctx, &runpb.ListRevisionsRequest{
+		Parent: fmt.Sprintf(SERVICE_FULL_NAME_FORMAT, opt.Project, opt.Region, opt.Service),
+	})
+	for revision, err := range it.All() {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list revisions: %w", err)
+		}
+		names = append(names, path.Base(revision.GetName()))
+	}
+
+	return names, nil
+}
+
+func (c *gcloud) UpdateServiceAnnotations(ctx context.Context, annotations map[string]string) error {
+	return c.UpdateServiceAnnotationsIfMatch(ctx, "", annotations)
+}
+
+// UpdateServiceAnnotationsIfMatch sends the etag with the update, so Cloud Run rejects it as well
+// when the service changes between reading and updating it. An empty etag updates unconditionally.
+func (c *gcloud) UpdateServiceAnnotationsIfMatch(ctx context.Context, etag string, annotations map[string]string) error {
+	service, err := c.GetService(ctx)
+	if err != nil {
+		return err
+	}
+	if etag != "" && service.GetEtag() != etag {
+		return grpcstatus.Errorf(codes.Aborted, "service %s changed since it was read", path.Base(service.GetName()))
+	}
+
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		if v == "" {
+			delete(service.Annotations, k)
+			continue
+		}
+		service.Annotations[k] = v
+	}
+
+	// Only the service metadata changes, so no revision is created.
+	op, err := c.ServicesClient.UpdateService(ctx, &runpb.UpdateServiceRequest{
+		Service:    service,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"annotations"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update service annotations: %w", err)
+	}
+
+	if _, err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to update service annotations: %w", err)
+	}
+
+	return nil
+}
+
+func (c *gcloud) GetActiveRevisionTags(ctx context.Context) ([]string, error) {
+	tagNames := []string{}
+	service, err := c.GetService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, tag := range service.Traffic {
 		if tag.Tag == "" {
 			continue
@@ -105,6 +189,49 @@ func (c *gcloud) GetActiveRevisionTags(ctx context.Context) ([]string, error) {
 	return tagNames, nil
 }
 
+// ListTags returns the tags of the service. The traffic of a tag is the traffic of its revision.
+func (c *gcloud) ListTags(ctx context.Context) ([]TagRecord, error) {
+	service, err := c.GetService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	split := TrafficSplit(service)
+	latest := path.Base(service.GetLatestReadyRevision())
+	revisions := map[string]*runpb.Revision{}
+
+	records := []TagRecord{}
+	for _, t := range service.GetTrafficStatuses() {
+		if t.GetTag() == "" {
+			continue
+		}
+
+		record := TagRecord{Tag: t.GetTag(), Revision: path.Base(t.GetRevision()), URL: t.GetUri()}
+		record.Traffic = split[record.Revision]
+		if record.Revision == latest {
+			record.Traffic += split[SWITCH_REVISION_DEFAULT_REVISION]
+		}
+
+		// Several tags may point to the same revision.
+		revision, ok := revisions[record.Revision]
+		if !ok {
+			revision, err = c.GetRevision(ctx, record.Revision)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get revision: %w", err)
+			}
+			revisions[record.Revision] = revision
+		}
+		record.Created = revision.GetCreateTime().AsTime()
+		if containers := revision.GetContainers(); len(containers) > 0 {
+			record.Image = containers[0].GetImage()
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
 func (c *gcloud) CreateRevision(ctx context.Context, imageName string, commitHash string) error {
 	if err := c.Deploy(ctx, imageName, commitHash, false); err != nil {
 		return fmt.Errorf("failed to create revision: %w", err)
@@ -113,12 +240,37 @@ func (c *gcloud) CreateRevision(ctx context.Context, imageName string, commitHas
 	return nil
 }
 
+// checkProtectedTags is called before every tag assignment, so no command moves a protected tag without --force.
+func (c *gcloud) checkProtectedTags(ctx context.Context, tags map[string]string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if IsForced(ctx) {
+		return nil
+	}
+	if !slices.ContainsFunc(slices.Collect(maps.Keys(tags)), func(tag string) bool { return IsProtectedTag(opt.ProtectedTags, tag) }) {
+		return nil
+	}
+
+	service, err := c.GetService(ctx)
+	if err != nil {
+		return err
+	}
+	return CheckProtectedTags(service, opt.ProtectedTags, tags)
+}
+
 func (c *gcloud) CreateRevisionTag(ctx context.Context, revisionTag string, revisionName string) error {
 	opt, err := GetCmdOption(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get cmdOption: %w", err)
 	}
 
+	if err := c.checkProtectedTags(ctx, map[string]string{revisionTag: revisionName}); err != nil {
+		return err
+	}
+
 	cmd := updateTrafficCmd(ctx, opt.Service, opt.Region, opt.Project)
 	cmd.Args = append(cmd.Args, "--update-tags", revisionTag+"="+revisionName)
 
@@ -128,6 +280,30 @@ func (c *gcloud) CreateRevisionTag(ctx context.Context, revisionTag string, revi
 	return nil
 }
 
+func (c *gcloud) UpdateRevisionTags(ctx context.Context, tags map[string]string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if err := c.checkProtectedTags(ctx, tags); err != nil {
+		return err
+	}
+
+	pairs := []string{}
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		pairs = append(pairs, tag+"="+tags[tag])
+	}
+
+	cmd := updateTrafficCmd(ctx, opt.Service, opt.Region, opt.Project)
+	cmd.Args = append(cmd.Args, "--update-tags", strings.Join(pairs, ","))
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+	return nil
+}
+
 func (c *gcloud) RemoveRevisionTag(ctx context.Context, revisionTag string) error {
 	opt, err := GetCmdOption(ctx)
 	if err != nil {
@@ -144,8 +320,18 @@ func (c *gcloud) RemoveRevisionTag(ctx context.Context, revisionTag string) erro
 	return nil
 }
 
+// RemoveRevisionTags removes the tags except the protected_tags, which are skipped.
 func (c *gcloud) RemoveRevisionTags(ctx context.Context, revisionTags []string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
 	for _, tag := range revisionTags {
+		if IsProtectedTag(opt.ProtectedTags, tag) {
+			fmt.Fprintf(Stdout(ctx), "Skipping protected tag %s\n", tag)
+			continue
+		}
 		if err := c.RemoveRevisionTag(ctx, tag); err != nil {
 			return fmt.Errorf("failed to remove tag: %w", err)
 		}
@@ -167,8 +353,10 @@ func (c *gcloud) Deploy(ctx context.Context, imageName string, commitHash string
 		cmd.Args = append(cmd.Args, "--revision-suffix", commitHash)
 	}
 
+	cmd.Args = append(cmd.Args, opt.Spec.DeployArgs()...)
+
 	if !useTraffic {
-		fmt.Println("Deploying without traffic")
+		fmt.Fprintln(Stdout(ctx), "Deploying without traffic")
 		cmd.Args = append(cmd.Args, "--no-traffic")
 	}
 
@@ -227,6 +415,27 @@ func (c *gcloud) UpdateTrafficToRevisionTag(ctx context.Context, tag string) err
 	return nil
 }
 
+func (c *gcloud) UpdateTrafficSplit(ctx context.Context, split map[string]int32) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	targets := []string{}
+	for _, revision := range slices.Sorted(maps.Keys(split)) {
+		targets = append(targets, fmt.Sprintf("%s=%d", revision, split[revision]))
+	}
+
+	cmd := updateTrafficCmd(ctx, opt.Service, opt.Region, opt.Project)
+	cmd.Args = append(cmd.Args, "--to-revisions", strings.Join(targets, ","))
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to update traffic split: %w", err)
+	}
+
+	return nil
+}
+
 func (c *gcloud) DeployWithTraffic(ctx context.Context, imageName string, commitHash string) error {
 	if err := c.Deploy(ctx, imageName, commitHash, true); err != nil {
 		return fmt.Errorf("failed to deploy to Cloud Run: %w", err)
@@ -245,8 +454,8 @@ func updateTrafficCmd(ctx context.Context, service string, region string, projec
 		"--project", project,
 	)
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = Stdout(ctx)
+	cmd.Stderr = Stderr(ctx)
 
 	return cmd
 }
@@ -257,8 +466,8 @@ func runDeployCmd(ctx context.Context, service string, region string, project st
 		"--region", region,
 	)
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = Stdout(ctx)
+	cmd.Stderr = Stderr(ctx)
 
 	return cmd
 }
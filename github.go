@@ -0,0 +1,318 @@
+package dekopin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_GITHUB_API_URL = "https://api.github.com"
+	GITHUB_TIMEOUT         = 30 * time.Second
+	MAX_GITHUB_RESPONSE    = 1 << 20
+
+	DEPLOYMENT_STATE_IN_PROGRESS = "in_progress"
+	DEPLOYMENT_STATE_SUCCESS     = "success"
+	DEPLOYMENT_STATE_FAILURE     = "failure"
+	DEPLOYMENT_STATE_INACTIVE    = "inactive"
+)
+
+// githubDeploymentCommands create a GitHub Deployment. remove-tag instead retires the preview of its tag.
+var githubDeploymentCommands = []string{"deploy", "create-tag", "st-deploy", "sr-deploy", "bluegreen deploy", "bluegreen promote"}
+
+// DefaultGitHubProductionEnvironments are the environments reported as production environments
+// when the configuration file does not list them.
+var DefaultGitHubProductionEnvironments = []string{"production"}
+
+// GitHubConfig connects dekopin to the repository the workflow runs for. It only applies on GitHub Actions.
+type GitHubConfig struct {
+	Deployments bool `yaml:"deployments,omitempty"`
+	// ProductionEnvironments lists the dekopin environments whose deployments are production deployments.
+	ProductionEnvironments []string `yaml:"production_environments,omitempty"`
+	// PRComments keeps a comment with the preview tags on the pull request the workflow runs for.
+	PRComments bool `yaml:"pr_comments,omitempty"`
+}
+
+// IsProduction reports whether the deployments to environment are production deployments.
+func (c *GitHubConfig) IsProduction(environment string) bool {
+	production := DefaultGitHubProductionEnvironments
+	if c != nil && c.ProductionEnvironments != nil {
+		production = c.ProductionEnvironments
+	}
+	return environment != "" && slices.Contains(production, environment)
+}
+
+// GitHubClient calls the GitHub REST API for one repository.
+type GitHubClient struct {
+	BaseURL    string
+	Token      string
+	Repository string // owner/name
+	HTTPClient *http.Client
+}
+
+// NewGitHubClient uses GITHUB_TOKEN and GITHUB_REPOSITORY of the runner. GITHUB_API_URL
+// overrides the API, as on GitHub Enterprise Server.
+func NewGitHubClient() (*GitHubClient, error) {
+	client := &GitHubClient{
+		BaseURL:    os.Getenv(ENV_GITHUB_API_URL),
+		Token:      os.Getenv(ENV_GITHUB_TOKEN),
+		Repository: os.Getenv(ENV_GITHUB_REPOSITORY),
+		HTTPClient: &http.Client{Timeout: GITHUB_TIMEOUT},
+	}
+	if client.BaseURL == "" {
+		client.BaseURL = DEFAULT_GITHUB_API_URL
+	}
+
+	if client.Token == "" || client.Repository == "" {
+		return nil, fmt.Errorf("%s and %s are required", ENV_GITHUB_TOKEN, ENV_GITHUB_REPOSITORY)
+	}
+
+	return client, nil
+}
+
+// GitHubDeployment is the part of a deployment dekopin reads back.
+type GitHubDeployment struct {
+	ID          int64  `json:"id"`
+	Environment string `json:"environment"`
+	Ref         string `json:"ref"`
+}
+
+// GitHubDeploymentRequest creates a deployment of ref to environment.
+type GitHubDeploymentRequest struct {
+	Ref         string
+	Environment string
+	Description string
+	// Transient environments, such as previews, are expected to go away.
+	Transient  bool
+	Production bool
+}
+
+func (c *GitHubClient) CreateDeployment(ctx context.Context, req GitHubDeploymentRequest) (*GitHubDeployment, error) {
+	body := map[string]any{
+		"ref":                    req.Ref,
+		"environment":            req.Environment,
+		"description":            req.Description,
+		"transient_environment":  req.Transient,
+		"production_environment": req.Production,
+		// dekopin runs after the checks of the workflow, and the ref may be a commit of a pull request.
+		"auto_merge":        false,
+		"required_contexts": []string{},
+	}
+
+	deployment := &GitHubDeployment{}
+	if err := c.do(ctx, http.MethodPost, "deployments", body, deployment); err != nil {
+		return nil, fmt.Errorf("failed to create deployment: %w", err)
+	}
+	return deployment, nil
+}
+
+// CreateDeploymentStatus sets the state of a deployment. A successful deployment makes the
+// earlier deployments of its environment inactive.
+func (c *GitHubClient) CreateDeploymentStatus(ctx context.Context, id int64, state string, environmentURL string, logURL string) error {
+	body := map[string]any{"state": state}
+	if environmentURL != "" {
+		body["environment_url"] = environmentURL
+	}
+	if logURL != "" {
+		body["log_url"] = logURL
+	}
+
+	if err := c.do(ctx, http.MethodPost, "deployments/"+strconv.FormatInt(id, 10)+"/statuses", body, nil); err != nil {
+		return fmt.Errorf("failed to set deployment %d to %s: %w", id, state, err)
+	}
+	return nil
+}
+
+func (c *GitHubClient) ListDeployments(ctx context.Context, environment string) ([]GitHubDeployment, error) {
+	deployments := []GitHubDeployment{}
+	if err := c.do(ctx, http.MethodGet, "deployments?per_page=100&environment="+url.QueryEscape(environment), nil, &deployments); err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	return deployments, nil
+}
+
+// DeactivateDeployments marks every deployment of environment inactive.
+func (c *GitHubClient) DeactivateDeployments(ctx context.Context, environment string) error {
+	deployments, err := c.ListDeployments(ctx, environment)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, d := range deployments {
+		if err := c.CreateDeploymentStatus(ctx, d.ID, DEPLOYMENT_STATE_INACTIVE, "", ""); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// do calls an endpoint of the repository and decodes the response into out, unless out is nil.
+func (c *GitHubClient) do(ctx context.Context, method string, endpoint string, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+"/repos/"+c.Repository+"/"+endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MAX_GITHUB_RESPONSE))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var message struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &message)
+		return fmt.Errorf("GitHub returned %s: %s", resp.Status, message.Message)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// GitHubEnvironment names the GitHub environment of a command run: the dekopin environment, or
+// the service when none is set. A successful deployment inactivates the others of its environment,
+// so the service and region are added when the command runs for several of them. Previews get an
+// environment per tag.
+func GitHubEnvironment(ctx context.Context, opt *CmdOption, tag string, preview bool) string {
+	name := opt.Environment
+	if name == "" {
+		name = opt.Service
+	} else if _, ok := GetServiceConfig(ctx); ok {
+		name += "/" + opt.Service
+	}
+	if IsMultiRegion(ctx) {
+		name += "/" + opt.Region
+	}
+	if preview && tag != "" {
+		name += "/" + tag
+	}
+	return name
+}
+
+// isPreview reports whether the command publishes a tag without moving traffic to it.
+func isPreview(dekopinCmd DekopinCommand) bool {
+	switch dekopinCmd.CommandName() {
+	case "bluegreen deploy":
+		return true
+	case "create-tag":
+		updateTraffic, err := dekopinCmd.GetUpdateTrafficByFlag()
+		return err == nil && !updateTraffic
+	}
+	return false
+}
+
+// withGitHubDeployment reports fn as a GitHub Deployment when github.deployments is on and the
+// runner is GitHub Actions. GitHub errors are reported but never fail the command.
+func withGitHubDeployment(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		dekopinCmd, err := GetDekopinCommand(ctx)
+		if err != nil {
+			return fn(ctx)
+		}
+
+		opt, err := GetCmdOption(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get cmdOption: %w", err)
+		}
+
+		command := dekopinCmd.CommandName()
+		if opt.GitHub == nil || !opt.GitHub.Deployments || opt.Runner != RUNNER_GITHUB_ACTIONS ||
+			(command != "remove-tag" && !slices.Contains(githubDeploymentCommands, command)) {
+			return fn(ctx)
+		}
+
+		client, err := NewGitHubClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: GitHub deployments are skipped: %s\n", err)
+			return fn(ctx)
+		}
+
+		tag := getHookVars(ctx)["TAG"]
+		if command == "remove-tag" {
+			if err := fn(ctx); err != nil {
+				return err
+			}
+			if err := client.DeactivateDeployments(ctx, GitHubEnvironment(ctx, opt, tag, true)); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: failed to deactivate the GitHub deployments of %s: %s\n", tag, err)
+			}
+			return nil
+		}
+
+		preview := isPreview(dekopinCmd)
+		deployment, err := client.CreateDeployment(ctx, GitHubDeploymentRequest{
+			Ref:         os.Getenv(ENV_GITHUB_SHA),
+			Environment: GitHubEnvironment(ctx, opt, tag, preview),
+			Description: fmt.Sprintf("dekopin %s: %s (%s)", command, opt.Service, opt.Region),
+			Transient:   preview,
+			Production:  !preview && opt.GitHub.IsProduction(opt.Environment),
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", err)
+			return fn(ctx)
+		}
+
+		logURL := runnerRunURL(opt)
+		if err := client.CreateDeploymentStatus(ctx, deployment.ID, DEPLOYMENT_STATE_IN_PROGRESS, "", logURL); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", err)
+		}
+
+		fnErr := fn(ctx)
+
+		// The result must be reported even when the command ran out of time.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), GITHUB_TIMEOUT)
+		defer cancel()
+
+		state, environmentURL := DEPLOYMENT_STATE_SUCCESS, ""
+		if fnErr != nil {
+			state = DEPLOYMENT_STATE_FAILURE
+		} else {
+			vars := commandVars(ctx, command)
+			environmentURL = vars["TAG_URL"]
+			if environmentURL == "" {
+				environmentURL = vars["URL"]
+			}
+		}
+		if err := client.CreateDeploymentStatus(ctx, deployment.ID, state, environmentURL, logURL); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", err)
+		}
+
+		return fnErr
+	}
+}
@@ -0,0 +1,327 @@
+package dekopin_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+// githubStandIn records the requests to the GitHub API and serves deployments from memory.
+type githubStandIn struct {
+	mu          sync.Mutex
+	requests    []string
+	deployments []dekopin.GitHubDeployment
+	statuses    map[int64][]map[string]any
+}
+
+func (g *githubStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "Bad credentials"}`)
+		return
+	}
+
+	g.requests = append(g.requests, r.Method+" "+r.URL.Path)
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/app/deployments":
+		d := dekopin.GitHubDeployment{ID: int64(len(g.deployments) + 1), Environment: body["environment"].(string), Ref: body["ref"].(string)}
+		g.deployments = append(g.deployments, d)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(d)
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/owner/app/deployments":
+		matched := []dekopin.GitHubDeployment{}
+		for _, d := range g.deployments {
+			if d.Environment == r.URL.Query().Get("environment") {
+				matched = append(matched, d)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(matched)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/statuses"):
+		var id int64
+		fmt.Sscanf(r.URL.Path, "/repos/owner/app/deployments/%d/statuses", &id)
+		g.statuses[id] = append(g.statuses[id], body)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found"}`)
+	}
+}
+
+func TestGitHubClientDeployments(t *testing.T) {
+	type TestResult struct {
+		Err      error
+		StandIn  *githubStandIn
+		Statuses map[int64][]map[string]any
+	}
+
+	type ArrangeResult struct {
+		token string
+		run   func(ctx context.Context, client *dekopin.GitHubClient) error
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_deployment_with_statuses": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					token: "test-token",
+					run: func(ctx context.Context, client *dekopin.GitHubClient) error {
+						d, err := client.CreateDeployment(ctx, dekopin.GitHubDeploymentRequest{Ref: "abc1234", Environment: "production", Production: true})
+						if err != nil {
+							return err
+						}
+						if err := client.CreateDeploymentStatus(ctx, d.ID, dekopin.DEPLOYMENT_STATE_IN_PROGRESS, "", "https://github.com/owner/app/actions/runs/1"); err != nil {
+							return err
+						}
+						return client.CreateDeploymentStatus(ctx, d.ID, dekopin.DEPLOYMENT_STATE_SUCCESS, "https://release---app.a.run.app", "")
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []dekopin.GitHubDeployment{{ID: 1, Environment: "production", Ref: "abc1234"}}, result.StandIn.deployments)
+				assert.Equal(t, []map[string]any{
+					{"state": "in_progress", "log_url": "https://github.com/owner/app/actions/runs/1"},
+					{"state": "success", "environment_url": "https://release---app.a.run.app"},
+				}, result.Statuses[1])
+			},
+		},
+		"success_deactivate_only_the_environment": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					token: "test-token",
+					run: func(ctx context.Context, client *dekopin.GitHubClient) error {
+						for _, env := range []string{"app/pr-1", "app/pr-2", "app/pr-1"} {
+							if _, err := client.CreateDeployment(ctx, dekopin.GitHubDeploymentRequest{Ref: "abc1234", Environment: env, Transient: true}); err != nil {
+								return err
+							}
+						}
+						return client.DeactivateDeployments(ctx, "app/pr-1")
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, map[int64][]map[string]any{
+					1: {{"state": "inactive"}},
+					3: {{"state": "inactive"}},
+				}, result.Statuses)
+			},
+		},
+		"error_bad_credentials": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					token: "wrong",
+					run: func(ctx context.Context, client *dekopin.GitHubClient) error {
+						_, err := client.CreateDeployment(ctx, dekopin.GitHubDeploymentRequest{Ref: "abc1234", Environment: "production"})
+						return err
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "401 Unauthorized: Bad credentials")
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			standIn := &githubStandIn{statuses: map[int64][]map[string]any{}}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client := &dekopin.GitHubClient{BaseURL: server.URL, Token: args.token, Repository: "owner/app", HTTPClient: server.Client()}
+			err := args.run(context.Background(), client)
+			tc.Assert(t, args, TestResult{Err: err, StandIn: standIn, Statuses: standIn.statuses})
+		})
+	}
+}
+
+func TestNewGitHubClient(t *testing.T) {
+	type TestResult struct {
+		Client *dekopin.GitHubClient
+		Err    error
+	}
+
+	type ArrangeResult struct {
+		env map[string]string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_default_api": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{env: map[string]string{"GITHUB_TOKEN": "t", "GITHUB_REPOSITORY": "owner/app", "GITHUB_API_URL": ""}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, "https://api.github.com", result.Client.BaseURL)
+				assert.Equal(t, "owner/app", result.Client.Repository)
+			},
+		},
+		"success_api_url_override": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{env: map[string]string{"GITHUB_TOKEN": "t", "GITHUB_REPOSITORY": "owner/app", "GITHUB_API_URL": "http://127.0.0.1:8080"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, "http://127.0.0.1:8080", result.Client.BaseURL)
+			},
+		},
+		"error_missing_token": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{env: map[string]string{"GITHUB_TOKEN": "", "GITHUB_REPOSITORY": "owner/app"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "GITHUB_TOKEN and GITHUB_REPOSITORY are required")
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			for k, v := range args.env {
+				t.Setenv(k, v)
+			}
+			client, err := dekopin.NewGitHubClient()
+			tc.Assert(t, args, TestResult{Client: client, Err: err})
+		})
+	}
+}
+
+func TestGitHubEnvironment(t *testing.T) {
+	type TestResult struct {
+		Environment string
+	}
+
+	type ArrangeResult struct {
+		ctx     context.Context
+		opt     *dekopin.CmdOption
+		tag     string
+		preview bool
+	}
+
+	// inRegion returns the context and option fn gets for the first region.
+	inRegion := func(opt *dekopin.CmdOption) (context.Context, *dekopin.CmdOption) {
+		var regionCtx context.Context
+		_ = dekopin.RunForEachRegion(dekopin.SetCmdOption(context.Background(), opt), false, func(ctx context.Context) error {
+			if regionCtx == nil {
+				regionCtx = ctx
+			}
+			return nil
+		})
+		regionOpt, _ := dekopin.GetCmdOption(regionCtx)
+		return regionCtx, regionOpt
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_environment": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{ctx: context.Background(), opt: &dekopin.CmdOption{Service: "app", Environment: "production"}, tag: "release"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, "production", result.Environment)
+			},
+		},
+		"success_preview_of_service": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{ctx: context.Background(), opt: &dekopin.CmdOption{Service: "app"}, tag: "pr-12", preview: true}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, "app/pr-12", result.Environment)
+			},
+		},
+		"success_environment_per_service": {
+			Arrange: func() ArrangeResult {
+				ctx := dekopin.SetServiceConfig(context.Background(), &dekopin.ServiceConfig{Name: "api"})
+				return ArrangeResult{ctx: ctx, opt: &dekopin.CmdOption{Service: "api", Environment: "production"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, "production/api", result.Environment)
+			},
+		},
+		"success_environment_per_region": {
+			Arrange: func() ArrangeResult {
+				ctx, opt := inRegion(&dekopin.CmdOption{
+					Project:     "test-project",
+					Service:     "app",
+					Runner:      dekopin.RUNNER_LOCAL,
+					Regions:     []string{"asia-northeast1", "us-central1"},
+					Environment: "production",
+					History:     &dekopin.HistoryConfig{Store: dekopin.HISTORY_STORE_NONE},
+				})
+				return ArrangeResult{ctx: ctx, opt: opt, tag: "pr-12", preview: true}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, "production/asia-northeast1/pr-12", result.Environment)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			tc.Assert(t, args, TestResult{Environment: dekopin.GitHubEnvironment(args.ctx, args.opt, args.tag, args.preview)})
+		})
+	}
+}
+
+func TestGitHubConfigIsProduction(t *testing.T) {
+	type TestResult struct {
+		Production bool
+	}
+
+	type ArrangeResult struct {
+		config      *dekopin.GitHubConfig
+		environment string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_production_by_default": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{config: &dekopin.GitHubConfig{Deployments: true}, environment: "production"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.True(t, result.Production)
+			},
+		},
+		"success_listed_environments_replace_the_default": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{config: &dekopin.GitHubConfig{ProductionEnvironments: []string{"prd"}}, environment: "production"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.False(t, result.Production)
+			},
+		},
+		"success_no_environment_is_not_production": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.False(t, result.Production)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			tc.Assert(t, args, TestResult{Production: args.config.IsProduction(args.environment)})
+		})
+	}
+}
@@ -0,0 +1,697 @@
+package dekopin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+const (
+	HISTORY_STORE_ANNOTATIONS = "annotations"
+	HISTORY_STORE_FILE        = "file"
+	HISTORY_STORE_NONE        = "none"
+
+	// ANNOTATION_HISTORY holds the history entries of the service as a JSON array.
+	ANNOTATION_HISTORY = "dekopin.dev/history"
+
+	DEFAULT_HISTORY_MAX_ENTRIES = 20
+	DEFAULT_HISTORY_PATH        = ".dekopin/history.jsonl"
+	// Annotations of a service share 256 KiB, so the annotations store keeps fewer entries.
+	MAX_ANNOTATION_HISTORY_ENTRIES = 100
+
+	// HISTORY_TIMEOUT bounds recording an entry, which happens after the command even when it timed out.
+	HISTORY_TIMEOUT = 30 * time.Second
+	// HISTORY_APPEND_ATTEMPTS bounds the retries of an entry when other commands change the service meanwhile.
+	HISTORY_APPEND_ATTEMPTS = 5
+)
+
+// HistoryConfig selects where the history of the mutating commands is kept.
+type HistoryConfig struct {
+	Store      string `yaml:"store,omitempty"` // annotations (default), file or none
+	Path       string `yaml:"path,omitempty"`  // For the file store
+	MaxEntries int    `yaml:"max_entries,omitempty"`
+}
+
+func (c *HistoryConfig) store() string {
+	if c == nil || c.Store == "" {
+		return HISTORY_STORE_ANNOTATIONS
+	}
+	return c.Store
+}
+
+func (c *HistoryConfig) maxEntries() int {
+	if c == nil || c.MaxEntries == 0 {
+		return DEFAULT_HISTORY_MAX_ENTRIES
+	}
+	return c.MaxEntries
+}
+
+func (c *HistoryConfig) path() string {
+	if c == nil || c.Path == "" {
+		return DEFAULT_HISTORY_PATH
+	}
+	return c.Path
+}
+
+// Enabled reports whether commands are recorded. History is on unless the store is none.
+func (c *HistoryConfig) Enabled() bool {
+	return c.store() != HISTORY_STORE_NONE
+}
+
+func (c *HistoryConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	errs := []error{}
+	if _, ok := historyStores[c.store()]; !ok && c.store() != HISTORY_STORE_NONE {
+		names := append(slices.Sorted(maps.Keys(historyStores)), HISTORY_STORE_NONE)
+		errs = append(errs, fmt.Errorf("history: unknown store %q. Valid values: %s", c.Store, strings.Join(names, ", ")))
+	}
+	if c.MaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("history: max_entries must not be negative"))
+	}
+	if c.store() == HISTORY_STORE_ANNOTATIONS && c.maxEntries() > MAX_ANNOTATION_HISTORY_ENTRIES {
+		errs = append(errs, fmt.Errorf("history: max_entries must be at most %d with the annotations store", MAX_ANNOTATION_HISTORY_ENTRIES))
+	}
+
+	return errors.Join(errs...)
+}
+
+// HistoryEntry records one run of a mutating command against one service in one region.
+type HistoryEntry struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	Command     string    `json:"command"`
+	Actor       string    `json:"actor,omitempty"`
+	Runner      string    `json:"runner,omitempty"`
+	Ref         string    `json:"ref,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	RunURL      string    `json:"run_url,omitempty"`
+	Project     string    `json:"project"`
+	Region      string    `json:"region"`
+	Service     string    `json:"service"`
+	Environment string    `json:"environment,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Tag         string    `json:"tag,omitempty"`
+	Revision    string    `json:"revision,omitempty"`
+	Result      string    `json:"result"`
+	Error       string    `json:"error,omitempty"`
+	// Traffic maps revisions, or LATEST, to their percent.
+	TrafficBefore map[string]int32 `json:"traffic_before,omitempty"`
+	TrafficAfter  map[string]int32 `json:"traffic_after,omitempty"`
+	// Overrides are the image policy and freeze overrides the command ran under.
+	Overrides []*PolicyOverride `json:"overrides,omitempty"`
+}
+
+// HistoryStore keeps the history of the service in the command options of ctx.
+type HistoryStore interface {
+	// Append adds an entry and drops the oldest entries beyond max.
+	Append(ctx context.Context, entry HistoryEntry, max int) error
+	// List returns the entries, oldest first.
+	List(ctx context.Context) ([]HistoryEntry, error)
+}
+
+// historyStores builds the store for history.store, keyed by the store name.
+var historyStores = map[string]func(ctx context.Context, config *HistoryConfig) (HistoryStore, error){
+	HISTORY_STORE_ANNOTATIONS: func(ctx context.Context, config *HistoryConfig) (HistoryStore, error) {
+		gc, err := GetGCloud(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get gcloud command: %w", err)
+		}
+		return &AnnotationHistoryStore{GCloud: gc}, nil
+	},
+	HISTORY_STORE_FILE: func(ctx context.Context, config *HistoryConfig) (HistoryStore, error) {
+		return &FileHistoryStore{Path: config.path()}, nil
+	},
+}
+
+// RegisterHistoryStore makes a history backend available to history.store.
+func RegisterHistoryStore(name string, factory func(ctx context.Context, config *HistoryConfig) (HistoryStore, error)) {
+	historyStores[name] = factory
+}
+
+func NewHistoryStore(ctx context.Context, config *HistoryConfig) (HistoryStore, error) {
+	factory, ok := historyStores[config.store()]
+	if !ok {
+		return nil, fmt.Errorf("history is disabled")
+	}
+	return factory(ctx, config)
+}
+
+// AnnotationHistoryStore keeps the history on the service itself, so every region has its own.
+type AnnotationHistoryStore struct {
+	GCloud GCloud
+}
+
+func (s *AnnotationHistoryStore) List(ctx context.Context) ([]HistoryEntry, error) {
+	entries, _, err := s.list(ctx)
+	return entries, err
+}
+
+// list also returns the etag of the service the entries were read from.
+func (s *AnnotationHistoryStore) list(ctx context.Context) ([]HistoryEntry, string, error) {
+	service, err := s.GCloud.GetService(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get service: %w", err)
+	}
+
+	value := service.GetAnnotations()[ANNOTATION_HISTORY]
+	if value == "" {
+		return nil, service.GetEtag(), nil
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, "", fmt.Errorf("failed to decode %s: %w", ANNOTATION_HISTORY, err)
+	}
+	return entries, service.GetEtag(), nil
+}
+
+// Append writes the history only if the service did not change since it was read, and reads it
+// again otherwise, so that the entries of commands running at the same time are all kept.
+func (s *AnnotationHistoryStore) Append(ctx context.Context, entry HistoryEntry, max int) error {
+	var err error
+	for range HISTORY_APPEND_ATTEMPTS {
+		err = s.append(ctx, entry, max)
+		if code := grpcstatus.Code(err); code != codes.Aborted && code != codes.FailedPrecondition {
+			return err
+		}
+	}
+	return fmt.Errorf("the service kept changing while recording history: %w", err)
+}
+
+func (s *AnnotationHistoryStore) append(ctx context.Context, entry HistoryEntry, max int) error {
+	entries, etag, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries = append(entries, entry)
+	if len(entries) > max {
+		entries = entries[len(entries)-max:]
+	}
+
+	value, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+
+	return s.GCloud.UpdateServiceAnnotationsIfMatch(ctx, etag, map[string]string{ANNOTATION_HISTORY: string(value)})
+}
+
+// FileHistoryStore keeps the history of every service as JSON lines in a local file.
+type FileHistoryStore struct {
+	Path string
+}
+
+// historyFileMu serializes the services of a command, which record concurrently.
+var historyFileMu sync.Mutex
+
+func (s *FileHistoryStore) List(ctx context.Context) ([]HistoryEntry, error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	key := historyKey(opt.Project, opt.Region, opt.Service)
+	return slices.DeleteFunc(entries, func(e HistoryEntry) bool {
+		return historyKey(e.Project, e.Region, e.Service) != key
+	}), nil
+}
+
+func (s *FileHistoryStore) Append(ctx context.Context, entry HistoryEntry, max int) error {
+	historyFileMu.Lock()
+	defer historyFileMu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+
+	// Keep the newest max entries of the entry's service; other services are untouched.
+	key := historyKey(entry.Project, entry.Region, entry.Service)
+	excess := -max
+	for _, e := range entries {
+		if historyKey(e.Project, e.Region, e.Service) == key {
+			excess++
+		}
+	}
+	entries = slices.DeleteFunc(entries, func(e HistoryEntry) bool {
+		if excess > 0 && historyKey(e.Project, e.Region, e.Service) == key {
+			excess--
+			return true
+		}
+		return false
+	})
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode history: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Replace the file in one step so that an interrupted write does not lose the history.
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileHistoryStore) read() ([]HistoryEntry, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	entries := []HistoryEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return entries, nil
+}
+
+func historyKey(project string, region string, service string) string {
+	return project + "/" + region + "/" + service
+}
+
+// withHistory records every run of fn by a mutating command, whatever its result. A failure to
+// record is reported but does not fail a command that already changed the service.
+func withHistory(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		dekopinCmd, err := GetDekopinCommand(ctx)
+		if err != nil || !slices.Contains(HookCommands, dekopinCmd.CommandName()) {
+			return fn(ctx)
+		}
+
+		opt, err := GetCmdOption(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get cmdOption: %w", err)
+		}
+
+		gc, err := GetGCloud(ctx)
+		if err != nil || !opt.History.Enabled() {
+			return fn(ctx)
+		}
+
+		// The service does not exist before its first deploy.
+		before, _ := gc.GetService(ctx)
+		start := time.Now()
+
+		fnErr := fn(ctx)
+
+		recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), HISTORY_TIMEOUT)
+		defer cancel()
+
+		after, _ := gc.GetService(recordCtx)
+		entry := NewHistoryEntry(ctx, dekopinCmd.CommandName(), start, fnErr)
+		if before != nil {
+			entry.TrafficBefore = TrafficSplit(before)
+		}
+		if after != nil {
+			entry.TrafficAfter = TrafficSplit(after)
+		}
+		if entry.Revision == "" || entry.Revision == SWITCH_REVISION_DEFAULT_REVISION {
+			entry.Revision = historyRevision(entry.Tag, after, before)
+		}
+
+		if err := recordHistory(recordCtx, opt.History, entry); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to record history: %s\n", err)
+		}
+
+		return fnErr
+	}
+}
+
+func recordHistory(ctx context.Context, config *HistoryConfig, entry HistoryEntry) error {
+	store, err := NewHistoryStore(ctx, config)
+	if err != nil {
+		return err
+	}
+	return store.Append(ctx, entry, config.maxEntries())
+}
+
+// newHistoryID is unique even for commands starting in the same millisecond, such as the
+// regions of a wave.
+func newHistoryID(start time.Time) string {
+	return fmt.Sprintf("%s-%04x", strconv.FormatInt(start.UnixMilli(), 36), rand.IntN(1<<16))
+}
+
+// NewHistoryEntry describes a run of command from the command options, the hook variables and the runner environment.
+func NewHistoryEntry(ctx context.Context, command string, start time.Time, err error) HistoryEntry {
+	entry := HistoryEntry{
+		ID:      newHistoryID(start),
+		Time:    start.UTC(),
+		Command: command,
+		Actor:   policyActor(),
+		Result:  HOOK_RESULT_SUCCESS,
+	}
+	if err != nil {
+		entry.Result = HOOK_RESULT_FAILURE
+		entry.Error = err.Error()
+	}
+
+	if opt, err := GetCmdOption(ctx); err == nil {
+		entry.Runner = opt.Runner
+		entry.Project = opt.Project
+		entry.Region = opt.Region
+		entry.Service = opt.Service
+		entry.Environment = opt.Environment
+		entry.Commit, entry.RunURL = runnerCommit(opt), runnerRunURL(opt)
+	}
+	if ref, err := GetRunnerRef(ctx); err == nil {
+		entry.Ref = ref
+	}
+
+	vars := getHookVars(ctx)
+	entry.Image = vars["IMAGE"]
+	entry.Tag = vars["TAG"]
+	entry.Revision = vars["REVISION"]
+
+	if override, ok := GetPolicyOverride(ctx); ok {
+		entry.Overrides = append(entry.Overrides, override)
+	}
+	if override, ok := GetEmergencyOverride(ctx); ok {
+		entry.Overrides = append(entry.Overrides, override)
+	}
+
+	return entry
+}
+
+// historyRevision returns the revision behind tag, or else the latest created revision.
+func historyRevision(tag string, after *runpb.Service, before *runpb.Service) string {
+	for _, service := range []*runpb.Service{after, before} {
+		for _, t := range service.GetTrafficStatuses() {
+			if tag != "" && t.GetTag() == tag && t.GetRevision() != "" {
+				return path.Base(t.GetRevision())
+			}
+		}
+	}
+
+	if after.GetLatestCreatedRevision() == "" {
+		return ""
+	}
+	return path.Base(after.GetLatestCreatedRevision())
+}
+
+func runnerCommit(opt *CmdOption) string {
+	switch opt.Runner {
+	case RUNNER_GITHUB_ACTIONS:
+		return os.Getenv(ENV_GITHUB_SHA)
+	case RUNNER_CLOUD_BUILD:
+		return os.Getenv(ENV_CLOUD_BUILD_SHA)
+	}
+	return ""
+}
+
+// runnerRunURL links the CI run that ran the command.
+func runnerRunURL(opt *CmdOption) string {
+	switch opt.Runner {
+	case RUNNER_GITHUB_ACTIONS:
+		server, repo, id := os.Getenv(ENV_GITHUB_SERVER_URL), os.Getenv(ENV_GITHUB_REPOSITORY), os.Getenv(ENV_GITHUB_RUN_ID)
+		if server != "" && repo != "" && id != "" {
+			return server + "/" + repo + "/actions/runs/" + id
+		}
+	case RUNNER_CLOUD_BUILD:
+		if id := os.Getenv(ENV_CLOUD_BUILD_ID); id != "" {
+			return "https://console.cloud.google.com/cloud-build/builds/" + id + "?project=" + opt.Project
+		}
+	}
+	return ""
+}
+
+var historyCmd = &cobra.Command{
+	Use:     "history",
+	Short:   "List the commands that changed the service",
+	PreRunE: historyPreRun,
+	RunE:    historyCommand,
+}
+
+var historyInspectCmd = &cobra.Command{
+	Use:     "inspect",
+	Short:   "Show one history entry with its traffic before and after",
+	PreRunE: historyPreRun,
+	RunE:    historyInspectCommand,
+}
+
+func historyPreRun(cmd *cobra.Command, args []string) error {
+	dekopinCmd, err := GetDekopinCommand(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	output, err := dekopinCmd.GetOutputByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+
+	if output != OUTPUT_TEXT && output != OUTPUT_JSON {
+		return fmt.Errorf("invalid output format. Valid values: text, json")
+	}
+
+	opt, err := GetCmdOption(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if !opt.History.Enabled() {
+		return fmt.Errorf("history is disabled by history.store: none")
+	}
+
+	return nil
+}
+
+func historyCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	output, err := dekopinCmd.GetOutputByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			opt, err := GetCmdOption(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get cmdOption: %w", err)
+			}
+
+			store, err := NewHistoryStore(ctx, opt.History)
+			if err != nil {
+				return err
+			}
+
+			entries, err := store.List(ctx)
+			if err != nil {
+				return err
+			}
+
+			return writeHistory(ctx, os.Stdout, entries, output)
+		})
+	})
+}
+
+// writeHistory lists the entries, newest first.
+func writeHistory(ctx context.Context, w io.Writer, entries []HistoryEntry, output string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	entries = slices.Clone(entries)
+	slices.Reverse(entries)
+
+	var buf bytes.Buffer
+	if output == OUTPUT_JSON {
+		if err := json.NewEncoder(&buf).Encode(map[string]any{
+			"service": opt.Service,
+			"region":  opt.Region,
+			"history": entries,
+		}); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	} else {
+		fmt.Fprintf(&buf, "Service: %s (%s)\n", opt.Service, opt.Region)
+		if len(entries) == 0 {
+			fmt.Fprintln(&buf, "No history")
+		} else {
+			tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTIME\tCOMMAND\tACTOR\tREVISION\tTAG\tRESULT")
+			for _, e := range entries {
+				result := e.Result
+				if len(e.Overrides) > 0 {
+					result += " (override)"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.Time.Format(time.RFC3339), e.Command, e.Actor, e.Revision, e.Tag, result)
+			}
+			if err := tw.Flush(); err != nil {
+				return fmt.Errorf("failed to write history: %w", err)
+			}
+		}
+		fmt.Fprintln(&buf)
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	return nil
+}
+
+func historyInspectCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	id, err := dekopinCmd.GetIDByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get id flag: %w", err)
+	}
+
+	output, err := dekopinCmd.GetOutputByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			opt, err := GetCmdOption(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get cmdOption: %w", err)
+			}
+
+			store, err := NewHistoryStore(ctx, opt.History)
+			if err != nil {
+				return err
+			}
+
+			entries, err := store.List(ctx)
+			if err != nil {
+				return err
+			}
+
+			i := slices.IndexFunc(entries, func(e HistoryEntry) bool { return e.ID == id })
+			if i < 0 {
+				return fmt.Errorf("history entry %s not found", id)
+			}
+
+			return writeHistoryEntry(os.Stdout, entries[i], output)
+		})
+	})
+}
+
+func writeHistoryEntry(w io.Writer, e HistoryEntry, output string) error {
+	var buf bytes.Buffer
+	if output == OUTPUT_JSON {
+		if err := json.NewEncoder(&buf).Encode(e); err != nil {
+			return fmt.Errorf("failed to write history entry: %w", err)
+		}
+	} else {
+		fields := [][2]string{
+			{"ID", e.ID},
+			{"Time", e.Time.Format(time.RFC3339)},
+			{"Command", e.Command},
+			{"Service", e.Service + " (" + e.Region + ")"},
+			{"Environment", e.Environment},
+			{"Actor", e.Actor},
+			{"Runner", e.Runner},
+			{"Ref", e.Ref},
+			{"Commit", e.Commit},
+			{"Run", e.RunURL},
+			{"Image", e.Image},
+			{"Tag", e.Tag},
+			{"Revision", e.Revision},
+			{"Result", e.Result},
+			{"Error", e.Error},
+		}
+		for _, f := range fields {
+			if f[1] != "" {
+				fmt.Fprintf(&buf, "%-16s %s\n", f[0]+":", f[1])
+			}
+		}
+		for _, o := range e.Overrides {
+			fmt.Fprintf(&buf, "%-16s %s by %s: %s\n", "Override:", strings.Join(o.Violations, "; "), o.Actor, o.Reason)
+		}
+
+		tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "REVISION\tBEFORE\tAFTER")
+		split := maps.Clone(e.TrafficBefore)
+		if split == nil {
+			split = map[string]int32{}
+		}
+		maps.Copy(split, e.TrafficAfter)
+		revisions := slices.Sorted(maps.Keys(split))
+		for _, r := range revisions {
+			fmt.Fprintf(tw, "%s\t%d%%\t%d%%\n", r, e.TrafficBefore[r], e.TrafficAfter[r])
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("failed to write history entry: %w", err)
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write history entry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+package dekopin_test
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFileHistoryStore(t *testing.T) {
+	type TestResult struct {
+		IDs []string
+		Err error
+	}
+
+	type ArrangeResult struct {
+		store   *dekopin.FileHistoryStore
+		appends []dekopin.HistoryEntry
+		max     int
+		service string
+	}
+
+	entry := func(id string, service string) dekopin.HistoryEntry {
+		return dekopin.HistoryEntry{
+			ID:      id,
+			Time:    time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+			Command: "deploy",
+			Project: "my-project",
+			Region:  "asia-northeast1",
+			Service: service,
+			Result:  "success",
+		}
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_lists_the_service_oldest_first": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					store:   &dekopin.FileHistoryStore{Path: filepath.Join(t.TempDir(), "history", "history.jsonl")},
+					appends: []dekopin.HistoryEntry{entry("a", "api"), entry("b", "web"), entry("c", "api")},
+					max:     10,
+					service: "api",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"a", "c"}, result.IDs)
+			},
+		},
+		"success_drops_the_oldest_entries_of_the_service": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					store:   &dekopin.FileHistoryStore{Path: filepath.Join(t.TempDir(), "history.jsonl")},
+					appends: []dekopin.HistoryEntry{entry("a", "api"), entry("b", "web"), entry("c", "api"), entry("d", "api")},
+					max:     2,
+					service: "api",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"c", "d"}, result.IDs)
+			},
+		},
+		"success_other_services_keep_their_entries": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					store:   &dekopin.FileHistoryStore{Path: filepath.Join(t.TempDir(), "history.jsonl")},
+					appends: []dekopin.HistoryEntry{entry("a", "api"), entry("b", "web"), entry("c", "api"), entry("d", "api")},
+					max:     1,
+					service: "web",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"b"}, result.IDs)
+			},
+		},
+		"success_missing_file_is_empty": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					store:   &dekopin.FileHistoryStore{Path: filepath.Join(t.TempDir(), "history.jsonl")},
+					service: "api",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Empty(t, result.IDs)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			ctx := dekopin.SetCmdOption(context.Background(), &dekopin.CmdOption{
+				Project: "my-project",
+				Region:  "asia-northeast1",
+				Service: args.service,
+			})
+
+			for _, e := range args.appends {
+				require.NoError(t, args.store.Append(ctx, e, args.max))
+			}
+
+			entries, err := args.store.List(ctx)
+			result := TestResult{Err: err, IDs: []string{}}
+			for _, e := range entries {
+				result.IDs = append(result.IDs, e.ID)
+			}
+			tc.Assert(t, args, result)
+		})
+	}
+}
+
+func TestHistoryConfigValidate(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		config *dekopin.HistoryConfig
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_default": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{config: nil}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_file_store_with_many_entries": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{config: &dekopin.HistoryConfig{Store: "file", MaxEntries: 1000}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_unknown_store": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{config: &dekopin.HistoryConfig{Store: "s3"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, `unknown store "s3". Valid values: annotations, file, none`)
+			},
+		},
+		"error_too_many_entries_for_annotations": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{config: &dekopin.HistoryConfig{MaxEntries: 500}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "at most 100")
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			tc.Assert(t, args, TestResult{Err: args.config.Validate()})
+		})
+	}
+}
+
+// annotationGCloud keeps the annotations of one service and changes its etag on every update,
+// rejecting updates whose etag is stale like Cloud Run does.
+type annotationGCloud struct {
+	dekopin.GCloud
+
+	mu          sync.Mutex
+	annotations map[string]string
+	etag        int
+}
+
+func (g *annotationGCloud) GetService(ctx context.Context) (*runpb.Service, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	annotations := map[string]string{}
+	for k, v := range g.annotations {
+		annotations[k] = v
+	}
+	return &runpb.Service{Annotations: annotations, Etag: strconv.Itoa(g.etag)}, nil
+}
+
+func (g *annotationGCloud) UpdateServiceAnnotationsIfMatch(ctx context.Context, etag string, annotations map[string]string) error {
+	// Yield before taking the lock, so concurrent appends read the same etag.
+	time.Sleep(time.Millisecond)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if etag != strconv.Itoa(g.etag) {
+		return status.Error(codes.Aborted, "etag mismatch")
+	}
+	for k, v := range annotations {
+		g.annotations[k] = v
+	}
+	g.etag++
+	return nil
+}
+
+func TestAnnotationHistoryStore(t *testing.T) {
+	type TestResult struct {
+		IDs []string
+		Err error
+	}
+
+	type ArrangeResult struct {
+		ids []string
+		max int
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_concurrent_appends_keep_every_entry": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ids: []string{"a", "b", "c"},
+					max: 10,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.ElementsMatch(t, []string{"a", "b", "c"}, result.IDs)
+			},
+		},
+		"success_drops_the_oldest_entries": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ids: []string{"a", "b", "c"},
+					max: 1,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Len(t, result.IDs, 1)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			gc := &annotationGCloud{annotations: map[string]string{}}
+			store := &dekopin.AnnotationHistoryStore{GCloud: gc}
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			for _, id := range args.ids {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					assert.NoError(t, store.Append(ctx, dekopin.HistoryEntry{ID: id}, args.max))
+				}()
+			}
+			wg.Wait()
+
+			entries, err := store.List(ctx)
+			result := TestResult{Err: err, IDs: []string{}}
+			for _, e := range entries {
+				result.IDs = append(result.IDs, e.ID)
+			}
+			tc.Assert(t, args, result)
+		})
+	}
+}
+
+func TestNewHistoryEntry(t *testing.T) {
+	ctx := dekopin.SetCmdOption(context.Background(), &dekopin.CmdOption{
+		Project: "my-project",
+		Region:  "asia-northeast1",
+		Service: "api",
+	})
+	start := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	// Regions of a wave start in the same millisecond.
+	ids := map[string]bool{}
+	for range 100 {
+		ids[dekopin.NewHistoryEntry(ctx, "deploy", start, nil).ID] = true
+	}
+	assert.Greater(t, len(ids), 90)
+}
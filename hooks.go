@@ -0,0 +1,235 @@
+package dekopin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	HOOK_FAILURE_ABORT = "abort"
+	HOOK_FAILURE_WARN  = "warn"
+
+	DEFAULT_HOOK_TIMEOUT = 5 * time.Minute
+	HOOK_WAIT_DELAY      = 5 * time.Second
+
+	HOOK_RESULT_SUCCESS = "success"
+	HOOK_RESULT_FAILURE = "failure"
+)
+
+// HookCommands are the commands hooks can be attached to.
+var HookCommands = []string{
+	"deploy", "create-revision", "create-tag", "remove-tag", "st-deploy", "sr-deploy",
+	"bluegreen deploy", "bluegreen promote", "bluegreen abort",
+}
+
+// CommandHooks run around one command, once per service and region.
+// Post hooks run after the command whatever its result; on_success and on_failure depending on it.
+type CommandHooks struct {
+	Pre       []Hook `yaml:"pre,omitempty"`
+	Post      []Hook `yaml:"post,omitempty"`
+	OnSuccess []Hook `yaml:"on_success,omitempty"`
+	OnFailure []Hook `yaml:"on_failure,omitempty"`
+}
+
+// Hook is a shell command run with the resolved context exported as DEKOPIN_* variables.
+type Hook struct {
+	Run     string        `yaml:"run"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Failure is abort (default) to fail the command, or warn to only report the failure.
+	Failure string `yaml:"failure,omitempty"`
+}
+
+func validateHooks(hooks map[string]*CommandHooks) error {
+	errs := []error{}
+	for _, command := range slices.Sorted(maps.Keys(hooks)) {
+		if !slices.Contains(HookCommands, command) {
+			errs = append(errs, fmt.Errorf("hooks: unknown command %q. Valid values: %s", command, strings.Join(HookCommands, ", ")))
+			continue
+		}
+
+		h := hooks[command]
+		if h == nil {
+			continue
+		}
+		for stage, list := range map[string][]Hook{"pre": h.Pre, "post": h.Post, "on_success": h.OnSuccess, "on_failure": h.OnFailure} {
+			for i, hook := range list {
+				if strings.TrimSpace(hook.Run) == "" {
+					errs = append(errs, fmt.Errorf("hooks.%s.%s[%d]: run is required", command, stage, i))
+				}
+				if hook.Failure != "" && hook.Failure != HOOK_FAILURE_ABORT && hook.Failure != HOOK_FAILURE_WARN {
+					errs = append(errs, fmt.Errorf("hooks.%s.%s[%d]: invalid failure %q. Valid values: abort, warn", command, stage, i, hook.Failure))
+				}
+				if hook.Timeout < 0 {
+					errs = append(errs, fmt.Errorf("hooks.%s.%s[%d]: timeout must not be negative", command, stage, i))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type hookVarsKey struct{}
+
+// WithHookVars adds values for the hooks, keyed without the DEKOPIN_ prefix, e.g. TAG or IMAGE.
+func WithHookVars(ctx context.Context, vars map[string]string) context.Context {
+	merged := maps.Clone(getHookVars(ctx))
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	for k, v := range vars {
+		if v != "" {
+			merged[k] = v
+		}
+	}
+	return context.WithValue(ctx, hookVarsKey{}, merged)
+}
+
+func getHookVars(ctx context.Context) map[string]string {
+	vars, _ := ctx.Value(hookVarsKey{}).(map[string]string)
+	return vars
+}
+
+// withHooks wraps fn with the hooks configured for the running command.
+func withHooks(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		opt, err := GetCmdOption(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get cmdOption: %w", err)
+		}
+
+		dekopinCmd, err := GetDekopinCommand(ctx)
+		if err != nil {
+			return fn(ctx)
+		}
+
+		hooks := opt.Hooks[dekopinCmd.CommandName()]
+		if hooks == nil {
+			return fn(ctx)
+		}
+
+		return RunWithHooks(ctx, dekopinCmd.CommandName(), hooks, fn)
+	}
+}
+
+// RunWithHooks runs the pre hooks, fn, and then the hooks for its result. An aborting pre hook
+// skips fn; an aborting hook afterwards fails the command even though fn succeeded.
+func RunWithHooks(ctx context.Context, command string, hooks *CommandHooks, fn func(ctx context.Context) error) error {
+	vars := hookEnv(ctx, command)
+
+	err := runHooks(ctx, "pre", hooks.Pre, vars)
+	if err == nil {
+		err = fn(ctx)
+		vars = hookEnv(ctx, command)
+	}
+
+	stage, then, result := "on_success", hooks.OnSuccess, HOOK_RESULT_SUCCESS
+	if err != nil {
+		stage, then, result = "on_failure", hooks.OnFailure, HOOK_RESULT_FAILURE
+		vars = append(vars, ENV_PREFIX+"ERROR="+err.Error())
+	}
+	vars = append(vars, ENV_PREFIX+"RESULT="+result)
+
+	return errors.Join(err, runHooks(ctx, stage, then, vars), runHooks(ctx, "post", hooks.Post, vars))
+}
+
+func runHooks(ctx context.Context, stage string, hooks []Hook, env []string) error {
+	errs := []error{}
+	for _, hook := range hooks {
+		err := runHook(ctx, hook, env)
+		if err == nil {
+			continue
+		}
+
+		if hook.Failure == HOOK_FAILURE_WARN {
+			fmt.Fprintf(os.Stderr, "WARNING: %s hook %q failed: %s\n", stage, hook.Run, err)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s hook %q failed: %w", stage, hook.Run, err))
+		if stage == "pre" {
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func runHook(ctx context.Context, hook Hook, env []string) error {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_HOOK_TIMEOUT
+	}
+
+	// Hooks such as migrations may outlast the command TIMEOUT, so only their own timeout applies.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	fmt.Fprintf(Stdout(ctx), "Running hook: %s\n", hook.Run)
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Run)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = Stdout(ctx)
+	cmd.Stderr = Stderr(ctx)
+	// Children of the shell may keep stdout open after it was killed.
+	cmd.WaitDelay = HOOK_WAIT_DELAY
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// hookEnv returns the DEKOPIN_* variables for the hooks.
+func hookEnv(ctx context.Context, command string) []string {
+	vars := commandVars(ctx, command)
+
+	env := []string{}
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		env = append(env, ENV_PREFIX+k+"="+vars[k])
+	}
+	return env
+}
+
+// commandVars returns the resolved context of a command run, keyed like the hook variables without
+// the DEKOPIN_ prefix. The revision and tag URL are looked up on the service when the command did
+// not provide them.
+func commandVars(ctx context.Context, command string) map[string]string {
+	vars := map[string]string{"COMMAND": command}
+	if opt, err := GetCmdOption(ctx); err == nil {
+		vars["PROJECT"] = opt.Project
+		vars["REGION"] = opt.Region
+		vars["SERVICE"] = opt.Service
+		vars["ENVIRONMENT"] = opt.Environment
+	}
+	maps.Copy(vars, getHookVars(ctx))
+
+	if gc, err := GetGCloud(ctx); err == nil {
+		if service, err := gc.GetService(ctx); err == nil {
+			for _, t := range service.GetTrafficStatuses() {
+				if vars["TAG"] != "" && t.GetTag() == vars["TAG"] {
+					vars["TAG_URL"] = t.GetUri()
+					if t.GetRevision() != "" {
+						vars["REVISION"] = path.Base(t.GetRevision())
+					}
+				}
+			}
+			if vars["REVISION"] == "" || vars["REVISION"] == SWITCH_REVISION_DEFAULT_REVISION {
+				vars["REVISION"] = path.Base(service.GetLatestReadyRevision())
+			}
+			vars["URL"] = service.GetUri()
+		}
+	}
+
+	return vars
+}
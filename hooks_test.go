@@ -0,0 +1,136 @@
+package dekopin_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWithHooks(t *testing.T) {
+	type TestResult struct {
+		Err error
+		Log string
+		Ran bool
+	}
+
+	type ArrangeResult struct {
+		hooks *dekopin.CommandHooks
+		fnErr error
+		log   string
+	}
+
+	// record appends a line to the log, so the test sees which hooks ran and what they saw.
+	record := func(log string, line string) string {
+		return `echo "` + line + `" >> ` + log
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_hooks_see_the_resolved_context": {
+			Arrange: func() ArrangeResult {
+				log := filepath.Join(t.TempDir(), "hooks.log")
+				return ArrangeResult{
+					hooks: &dekopin.CommandHooks{
+						Pre:       []dekopin.Hook{{Run: record(log, "pre $DEKOPIN_COMMAND $DEKOPIN_SERVICE $DEKOPIN_TAG")}},
+						OnSuccess: []dekopin.Hook{{Run: record(log, "success $DEKOPIN_RESULT")}},
+						OnFailure: []dekopin.Hook{{Run: record(log, "failure")}},
+						Post:      []dekopin.Hook{{Run: record(log, "post $DEKOPIN_RESULT")}},
+					},
+					log: log,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.True(t, result.Ran)
+				assert.Equal(t, "pre deploy my-service tag-123\nsuccess success\npost success\n", result.Log)
+			},
+		},
+		"success_failing_warn_hook_does_not_abort": {
+			Arrange: func() ArrangeResult {
+				log := filepath.Join(t.TempDir(), "hooks.log")
+				return ArrangeResult{
+					hooks: &dekopin.CommandHooks{
+						Pre:  []dekopin.Hook{{Run: "exit 1", Failure: dekopin.HOOK_FAILURE_WARN}},
+						Post: []dekopin.Hook{{Run: record(log, "post $DEKOPIN_RESULT")}},
+					},
+					log: log,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.True(t, result.Ran)
+				assert.Equal(t, "post success\n", result.Log)
+			},
+		},
+		"error_failing_pre_hook_skips_the_command": {
+			Arrange: func() ArrangeResult {
+				log := filepath.Join(t.TempDir(), "hooks.log")
+				return ArrangeResult{
+					hooks: &dekopin.CommandHooks{
+						Pre:       []dekopin.Hook{{Run: "exit 3"}, {Run: record(log, "second pre")}},
+						OnFailure: []dekopin.Hook{{Run: record(log, "failure $DEKOPIN_RESULT")}},
+					},
+					log: log,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, `pre hook "exit 3" failed`)
+				assert.False(t, result.Ran)
+				assert.Equal(t, "failure failure\n", result.Log)
+			},
+		},
+		"error_command_failure_runs_on_failure_hooks": {
+			Arrange: func() ArrangeResult {
+				log := filepath.Join(t.TempDir(), "hooks.log")
+				return ArrangeResult{
+					hooks: &dekopin.CommandHooks{
+						OnSuccess: []dekopin.Hook{{Run: record(log, "success")}},
+						OnFailure: []dekopin.Hook{{Run: record(log, "failure $DEKOPIN_ERROR")}},
+					},
+					fnErr: errors.New("deploy failed"),
+					log:   log,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "deploy failed")
+				assert.Equal(t, "failure deploy failed\n", result.Log)
+			},
+		},
+		"error_hook_timeout": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					hooks: &dekopin.CommandHooks{
+						Post: []dekopin.Hook{{Run: "exec sleep 5", Timeout: 50 * time.Millisecond}},
+					},
+					log: filepath.Join(t.TempDir(), "hooks.log"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "timed out after 50ms")
+				assert.True(t, result.Ran)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			ctx := dekopin.SetCmdOption(context.Background(), &dekopin.CmdOption{Service: "my-service"})
+			ctx = dekopin.WithHookVars(ctx, map[string]string{"TAG": "tag-123"})
+
+			ran := false
+			err := dekopin.RunWithHooks(ctx, "deploy", ar.hooks, func(ctx context.Context) error {
+				ran = true
+				return ar.fnErr
+			})
+
+			log, _ := os.ReadFile(ar.log)
+			c.Assert(t, ar, TestResult{Err: err, Log: string(log), Ran: ran})
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package dekopin
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// HTTP_TIMEOUT bounds every request to the services and the notification and metrics endpoints.
+	HTTP_TIMEOUT = 60 * time.Second
+)
+
+func NewHTTPClient() *http.Client {
+	return &http.Client{Timeout: HTTP_TIMEOUT}
+}
+
+type httpClientKey struct{}
+
+func SetHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, httpClientKey{}, client)
+}
+
+// GetHTTPClient returns the client in ctx, or a new client with HTTP_TIMEOUT.
+func GetHTTPClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(httpClientKey{}).(*http.Client); ok {
+		return client
+	}
+	return NewHTTPClient()
+}
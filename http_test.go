@@ -0,0 +1,57 @@
+package dekopin_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHTTPClient(t *testing.T) {
+	type TestResult struct {
+		Client *http.Client
+	}
+
+	type ArrangeResult struct {
+		ctx    context.Context
+		client *http.Client
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_returns_the_client_of_the_context": {
+			Arrange: func() ArrangeResult {
+				client := &http.Client{Timeout: time.Second}
+				return ArrangeResult{
+					ctx:    dekopin.SetHTTPClient(context.Background(), client),
+					client: client,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Same(t, assertArgs.client, result.Client)
+			},
+		},
+		"success_without_a_client_returns_one_with_a_timeout": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ctx: context.Background(),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NotSame(t, http.DefaultClient, result.Client)
+				assert.Equal(t, dekopin.HTTP_TIMEOUT, result.Client.Timeout)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Client: dekopin.GetHTTPClient(ar.ctx),
+			})
+		})
+	}
+}
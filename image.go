@@ -0,0 +1,382 @@
+package dekopin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_REGISTRY   = "docker.io"
+	DEFAULT_IMAGE_TAG  = "latest"
+	DOCKER_HUB_API_URL = "registry-1.docker.io"
+
+	// ANNOTATION_SOURCE_IMAGE_PREFIX is followed by the revision name. The value is the
+	// image reference given to dekopin before it was resolved to a digest.
+	ANNOTATION_SOURCE_IMAGE_PREFIX = "image.dekopin.dev/"
+
+	REGISTRY_TIMEOUT           = 30 * time.Second
+	MAX_REGISTRY_RESPONSE_SIZE = 4 << 20
+)
+
+// manifestMediaTypes are accepted when resolving a tag, so that multi-platform images resolve to their index.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// googleRegistrySuffixes are the registries that accept a gcloud access token.
+var googleRegistrySuffixes = []string{"gcr.io", "-docker.pkg.dev"}
+
+// ImageRef is a parsed image reference of the form registry/repository:tag@digest.
+type ImageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageRef parses an image reference. The registry defaults to Docker Hub and the tag to latest.
+func ParseImageRef(image string) (ImageRef, error) {
+	if image == "" || strings.ContainsAny(image, " \t\n") {
+		return ImageRef{}, fmt.Errorf("invalid image reference %q", image)
+	}
+
+	ref := ImageRef{}
+	name := image
+	if before, digest, ok := strings.Cut(name, "@"); ok {
+		if !strings.Contains(digest, ":") {
+			return ImageRef{}, fmt.Errorf("invalid image reference %q: digest must be algorithm:hex", image)
+		}
+		name, ref.Digest = before, digest
+	}
+
+	// A colon after the last slash separates the tag; a colon before it belongs to the registry port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+
+	first, rest, ok := strings.Cut(name, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, ref.Repository = first, rest
+	} else {
+		ref.Registry, ref.Repository = DEFAULT_REGISTRY, name
+		if !ok {
+			ref.Repository = "library/" + name
+		}
+	}
+
+	if ref.Repository == "" || (ref.Tag == "" && ref.Digest == "" && strings.HasSuffix(image, ":")) {
+		return ImageRef{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = DEFAULT_IMAGE_TAG
+	}
+
+	return ref, nil
+}
+
+// Name returns the reference without tag and digest.
+func (r ImageRef) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the reference in its canonical form.
+func (r ImageRef) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// RegistryClient talks to the OCI distribution API of container registries.
+type RegistryClient struct {
+	HTTPClient *http.Client
+	// Credentials returns basic auth credentials for a registry. Empty values mean anonymous access.
+	Credentials func(ctx context.Context, registry string) (string, string, error)
+}
+
+func NewRegistryClient() *RegistryClient {
+	return &RegistryClient{
+		HTTPClient:  &http.Client{Timeout: REGISTRY_TIMEOUT},
+		Credentials: gcloudRegistryCredentials,
+	}
+}
+
+type registryClientKey struct{}
+
+func SetRegistryClient(ctx context.Context, client *RegistryClient) context.Context {
+	return context.WithValue(ctx, registryClientKey{}, client)
+}
+
+// GetRegistryClient returns the client in ctx, or a client for the real registries.
+func GetRegistryClient(ctx context.Context) *RegistryClient {
+	if client, ok := ctx.Value(registryClientKey{}).(*RegistryClient); ok {
+		return client
+	}
+	return NewRegistryClient()
+}
+
+// ResolveDigest returns the digest of the manifest the reference's tag points to.
+func (c *RegistryClient) ResolveDigest(ctx context.Context, ref ImageRef) (string, error) {
+	resp, _, err := c.get(ctx, http.MethodHead, ref, "manifests/"+ref.Tag, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to resolve %s: registry returned %s", ref, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("failed to resolve %s: registry returned no digest", ref)
+	}
+
+	return digest, nil
+}
+
+// get requests a resource of the repository, such as manifests/<reference> or blobs/<digest>,
+// and retries once with a token when the registry answers with a Bearer challenge.
+func (c *RegistryClient) get(ctx context.Context, method string, ref ImageRef, resource string, accept []string) (*http.Response, []byte, error) {
+	endpoint := registryURL(ref.Registry) + "/v2/" + ref.Repository + "/" + resource
+
+	resp, body, err := c.do(ctx, method, endpoint, accept, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := c.token(ctx, ref, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return c.do(ctx, method, endpoint, accept, token)
+	}
+
+	return resp, body, nil
+}
+
+func (c *RegistryClient) do(ctx context.Context, method string, endpoint string, accept []string, token string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create registry request: %w", err)
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to request %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MAX_REGISTRY_RESPONSE_SIZE))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", endpoint, err)
+	}
+
+	return resp, body, nil
+}
+
+// token follows a Bearer challenge and exchanges the registry credentials for a pull token.
+func (c *RegistryClient) token(ctx context.Context, ref ImageRef, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("failed to authenticate to %s: unsupported registry authentication %q", ref.Registry, challenge)
+	}
+
+	values := parseChallenge(params)
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return "", fmt.Errorf("failed to authenticate to %s: invalid registry challenge %q", ref.Registry, challenge)
+	}
+
+	query := realm.Query()
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	query.Set("scope", "repository:"+ref.Repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+
+	if c.Credentials != nil {
+		username, password, err := c.Credentials(ctx, ref.Registry)
+		if err != nil {
+			return "", err
+		}
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get registry token for %s: %s", ref.Name(), resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	if body.Token == "" {
+		body.Token = body.AccessToken
+	}
+
+	return body.Token, nil
+}
+
+// parseChallenge parses the comma separated key="value" pairs of a WWW-Authenticate header.
+func parseChallenge(params string) map[string]string {
+	values := map[string]string{}
+	for _, part := range strings.Split(params, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			values[k] = strings.Trim(v, `"`)
+		}
+	}
+	return values
+}
+
+// registryURL returns the API base URL of a registry. Local registries are reached over plain HTTP.
+func registryURL(registry string) string {
+	if registry == DEFAULT_REGISTRY {
+		registry = DOCKER_HUB_API_URL
+	}
+
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return "http://" + registry
+	}
+
+	return "https://" + registry
+}
+
+// gcloudRegistryCredentials authenticates to Google registries with the gcloud access token.
+func gcloudRegistryCredentials(ctx context.Context, registry string) (string, string, error) {
+	isGoogle := false
+	for _, suffix := range googleRegistrySuffixes {
+		isGoogle = isGoogle || strings.HasSuffix(registry, suffix)
+	}
+	if !isGoogle {
+		return "", "", nil
+	}
+
+	out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get access token for %s: %w", registry, err)
+	}
+
+	return "oauth2accesstoken", strings.TrimSpace(string(out)), nil
+}
+
+// ResolveImage pins image to a digest. Images already pinned are returned as is, and the
+// image policy may forbid resolving tags at all.
+func ResolveImage(ctx context.Context, image string) (string, error) {
+	ref, err := ParseImageRef(image)
+	if err != nil {
+		return "", err
+	}
+
+	if ref.Digest != "" {
+		return image, nil
+	}
+
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if opt.ImagePolicy != nil && opt.ImagePolicy.RequireDigest {
+		return "", fmt.Errorf("image %s is not pinned to a digest. image_policy.require_digest requires image@sha256:...", image)
+	}
+
+	digest, err := GetRegistryClient(ctx).ResolveDigest(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	resolved := ref.Name() + "@" + digest
+	fmt.Fprintf(Stdout(ctx), "Resolved %s to %s\n", image, resolved)
+	return resolved, nil
+}
+
+// recordSourceImage annotates the service with the image reference the latest created revision was deployed from.
+func recordSourceImage(ctx context.Context, gc GCloud, source string, resolved string) error {
+	if source == "" || source == resolved {
+		return nil
+	}
+
+	return annotateLatestRevision(ctx, gc, ANNOTATION_SOURCE_IMAGE_PREFIX, source)
+}
+
+// annotateLatestRevision records value on the service under prefix followed by the name of the
+// latest created revision. Revisions are immutable once created, so the service carries the record.
+// The records of deleted revisions are dropped, as all annotations share a size limit.
+func annotateLatestRevision(ctx context.Context, gc GCloud, prefix string, value string) error {
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return err
+	}
+
+	revision := service.GetLatestCreatedRevision()
+	if revision == "" {
+		return errors.New("the service has no created revision")
+	}
+
+	revisions, err := gc.ListRevisions(ctx)
+	if err != nil {
+		return err
+	}
+
+	annotations := PruneRevisionAnnotations(service.GetAnnotations(), prefix, revisions)
+	annotations[prefix+path.Base(revision)] = value
+	return gc.UpdateServiceAnnotations(ctx, annotations)
+}
+
+// PruneRevisionAnnotations returns the annotations under prefix whose revision is not in revisions,
+// set to "" so that UpdateServiceAnnotations removes them.
+func PruneRevisionAnnotations(annotations map[string]string, prefix string, revisions []string) map[string]string {
+	pruned := map[string]string{}
+	for k := range annotations {
+		if revision, ok := strings.CutPrefix(k, prefix); ok && !slices.Contains(revisions, revision) {
+			pruned[k] = ""
+		}
+	}
+	return pruned
+}
@@ -0,0 +1,175 @@
+package dekopin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ImagePolicy controls which image references may be deployed.
+type ImagePolicy struct {
+	RequireDigest bool `yaml:"require_digest,omitempty"`
+	// Allowed lists glob patterns of registry/repository per environment. Environments without
+	// an entry accept any image.
+	Allowed map[string][]string `yaml:"allowed,omitempty"`
+}
+
+func (p *ImagePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+
+	errs := []error{}
+	for _, environment := range slices.Sorted(maps.Keys(p.Allowed)) {
+		for _, pattern := range p.Allowed[environment] {
+			if strings.TrimSpace(pattern) == "" {
+				errs = append(errs, fmt.Errorf("image_policy.allowed.%s: pattern must not be empty", environment))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckImage rejects image when the environment has an allowlist and no pattern matches it.
+func (p *ImagePolicy) CheckImage(image string, environment string) error {
+	if p == nil || environment == "" {
+		return nil
+	}
+
+	patterns, ok := p.Allowed[environment]
+	if !ok {
+		return nil
+	}
+
+	ref, err := ParseImageRef(image)
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range patterns {
+		if MatchImagePattern(pattern, ref.Name()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("image %s is not allowed in the %s environment. Allowed: %s", ref.Name(), environment, strings.Join(patterns, ", "))
+}
+
+// MatchImagePattern matches registry/repository against a glob pattern.
+// * matches within one path segment and ** across segments.
+func MatchImagePattern(pattern string, name string) bool {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String()).MatchString(name)
+}
+
+// PolicyOverride records who deployed an image the policy rejected, and why.
+type PolicyOverride struct {
+	Reason      string    `json:"reason"`
+	Actor       string    `json:"actor"`
+	Environment string    `json:"environment"`
+	Violations  []string  `json:"violations"`
+	Time        time.Time `json:"time"`
+}
+
+type policyOverrideKey struct{}
+
+func SetPolicyOverride(ctx context.Context, override *PolicyOverride) context.Context {
+	return context.WithValue(ctx, policyOverrideKey{}, override)
+}
+
+func GetPolicyOverride(ctx context.Context) (*PolicyOverride, bool) {
+	override, ok := ctx.Value(policyOverrideKey{}).(*PolicyOverride)
+	return override, ok
+}
+
+// checkImagePolicy is called from the pre-run of the commands that create revisions.
+// Violations fail the command unless --override-policy gives a reason, in which case the
+// override is logged and kept in the context to be recorded with the revision.
+func checkImagePolicy(cmd *cobra.Command, images []string) error {
+	ctx := cmd.Context()
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	reason, err := dekopinCmd.GetOverridePolicyByFlag()
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("override-policy") && strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("--override-policy requires a reason")
+	}
+
+	violations := []string{}
+	errs := []error{}
+	for _, image := range images {
+		if err := opt.ImagePolicy.CheckImage(image, opt.Environment); err != nil {
+			violations = append(violations, image)
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	if reason == "" {
+		return fmt.Errorf("%w\nrefusing to continue. Pass --override-policy \"<reason>\" to deploy anyway", errors.Join(errs...))
+	}
+
+	override := &PolicyOverride{
+		Reason:      reason,
+		Actor:       policyActor(),
+		Environment: opt.Environment,
+		Violations:  violations,
+		Time:        time.Now().UTC(),
+	}
+	record, err := json.Marshal(override)
+	if err != nil {
+		return fmt.Errorf("failed to encode policy override: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "AUDIT: image policy overridden: %s\n", record)
+
+	cmd.SetContext(SetPolicyOverride(ctx, override))
+	return nil
+}
+
+// policyActor names who ran dekopin, for the audit record.
+func policyActor() string {
+	for _, key := range []string{"GITHUB_ACTOR", "USER"} {
+		if actor := os.Getenv(key); actor != "" {
+			return actor
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,78 @@
+package dekopin_test
+
+import (
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImagePolicyCheckImage(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		policy      *dekopin.ImagePolicy
+		image       string
+		environment string
+	}
+
+	policy := &dekopin.ImagePolicy{
+		Allowed: map[string][]string{
+			"production": {"asia-northeast1-docker.pkg.dev/my-project/*/app", "gcr.io/my-project/**"},
+		},
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_single_segment_glob": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{policy: policy, image: "asia-northeast1-docker.pkg.dev/my-project/backend/app:v1", environment: "production"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_double_star_glob": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{policy: policy, image: "gcr.io/my-project/team/api@sha256:abc", environment: "production"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_environment_without_allowlist": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{policy: policy, image: "docker.io/random/image", environment: "staging"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_single_star_does_not_cross_segments": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{policy: policy, image: "asia-northeast1-docker.pkg.dev/my-project/a/b/app", environment: "production"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Error(t, result.Err)
+			},
+		},
+		"error_docker_hub_image_in_production": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{policy: policy, image: "random/image", environment: "production"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "image docker.io/random/image is not allowed in the production environment")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Err: ar.policy.CheckImage(ar.image, ar.environment),
+			})
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package dekopin_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDigest = "sha256:4f1b0a8c3d5e7f9a2b4c6d8e0f1a3b5c7d9e1f3a5b7c9d1e3f5a7b9c1d3e5f7a"
+
+// newTestRegistry starts a registry stand-in serving one manifest. With auth it requires a bearer token.
+func newTestRegistry(t *testing.T, auth bool) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"token":"pull-token"}`))
+		case auth && r.Header.Get("Authorization") != "Bearer pull-token":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test-registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method == http.MethodHead && r.URL.Path == "/v2/team/app/manifests/v1.2.3":
+			if !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", testDigest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseImageRef(t *testing.T) {
+	type TestResult struct {
+		Ref dekopin.ImageRef
+		Err error
+	}
+
+	type ArrangeResult struct {
+		image string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_artifact_registry_with_tag": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{image: "asia-northeast1-docker.pkg.dev/project/repo/app:v1"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, dekopin.ImageRef{Registry: "asia-northeast1-docker.pkg.dev", Repository: "project/repo/app", Tag: "v1"}, result.Ref)
+			},
+		},
+		"success_registry_port_and_default_tag": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{image: "localhost:5000/team/app"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, dekopin.ImageRef{Registry: "localhost:5000", Repository: "team/app", Tag: "latest"}, result.Ref)
+			},
+		},
+		"success_docker_hub_official_image_with_digest": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{image: "nginx@" + testDigest}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, dekopin.ImageRef{Registry: "docker.io", Repository: "library/nginx", Digest: testDigest}, result.Ref)
+			},
+		},
+		"error_invalid_digest": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{image: "gcr.io/project/app@latest"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Error(t, result.Err)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			ref, err := dekopin.ParseImageRef(ar.image)
+			c.Assert(t, ar, TestResult{Ref: ref, Err: err})
+		})
+	}
+}
+
+func TestResolveImage(t *testing.T) {
+	type TestResult struct {
+		Image string
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		ctx   context.Context
+		image string
+	}
+
+	servers := map[bool]*httptest.Server{false: newTestRegistry(t, false), true: newTestRegistry(t, true)}
+	arrange := func(auth bool, policy *dekopin.ImagePolicy, image string) ArrangeResult {
+		server := servers[auth]
+		ctx := dekopin.SetRegistryClient(context.Background(), &dekopin.RegistryClient{HTTPClient: server.Client()})
+		ctx = dekopin.SetCmdOption(ctx, &dekopin.CmdOption{ImagePolicy: policy})
+		return ArrangeResult{
+			ctx:   ctx,
+			image: strings.ReplaceAll(image, "REGISTRY", strings.TrimPrefix(server.URL, "http://")),
+		}
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_resolves_tag_to_digest": {
+			Arrange: func() ArrangeResult {
+				return arrange(false, nil, "REGISTRY/team/app:v1.2.3")
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, strings.TrimSuffix(assertArgs.image, ":v1.2.3")+"@"+testDigest, result.Image)
+			},
+		},
+		"success_resolves_with_bearer_token": {
+			Arrange: func() ArrangeResult {
+				return arrange(true, nil, "REGISTRY/team/app:v1.2.3")
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.True(t, strings.HasSuffix(result.Image, "/team/app@"+testDigest))
+			},
+		},
+		"success_keeps_digest_reference": {
+			Arrange: func() ArrangeResult {
+				return arrange(false, &dekopin.ImagePolicy{RequireDigest: true}, "REGISTRY/team/app@"+testDigest)
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, assertArgs.image, result.Image)
+			},
+		},
+		"error_unknown_tag": {
+			Arrange: func() ArrangeResult {
+				return arrange(false, nil, "REGISTRY/team/app:missing")
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "404")
+			},
+		},
+		"error_policy_requires_digest": {
+			Arrange: func() ArrangeResult {
+				return arrange(false, &dekopin.ImagePolicy{RequireDigest: true}, "REGISTRY/team/app:v1.2.3")
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "require_digest")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			image, err := dekopin.ResolveImage(ar.ctx, ar.image)
+			c.Assert(t, ar, TestResult{Image: image, Err: err})
+		})
+	}
+}
+
+func TestPruneRevisionAnnotations(t *testing.T) {
+	type TestResult struct {
+		Pruned map[string]string
+	}
+
+	type ArrangeResult struct {
+		annotations map[string]string
+		revisions   []string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_removes_the_records_of_deleted_revisions_only": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					annotations: map[string]string{
+						dekopin.ANNOTATION_SOURCE_IMAGE_PREFIX + "api-abc1234": "gcr.io/p/api:v1",
+						dekopin.ANNOTATION_SOURCE_IMAGE_PREFIX + "api-def5678": "gcr.io/p/api:v2",
+						"dekopin.dev/history": "[]",
+					},
+					revisions: []string{"api-def5678"},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, map[string]string{dekopin.ANNOTATION_SOURCE_IMAGE_PREFIX + "api-abc1234": ""}, result.Pruned)
+			},
+		},
+		"success_nothing_to_remove_without_annotations": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{revisions: []string{"api-def5678"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Empty(t, result.Pruned)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Pruned: dekopin.PruneRevisionAnnotations(ar.annotations, dekopin.ANNOTATION_SOURCE_IMAGE_PREFIX, ar.revisions),
+			})
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package dekopin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var initCmd = &cobra.Command{
+	Use:               "init",
+	Short:             "Create dekopin.yml, optionally from an existing Cloud Run service",
+	PersistentPreRunE: prepareInitRun,
+	RunE:              initCommand,
+}
+
+// prepareInitRun only applies environment variables, because the command options are what init asks for.
+func prepareInitRun(cmd *cobra.Command, args []string) error {
+	if err := prepareOfflineRun(cmd, args); err != nil {
+		return err
+	}
+
+	cmd.SetContext(SetDekopinCommand(cmd.Context(), NewDekopinCommand(cmd)))
+	return nil
+}
+
+func initCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	fileName, err := dekopinCmd.GetFileByFlag()
+	if err != nil {
+		return err
+	}
+
+	force, err := dekopinCmd.GetForceByFlag()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(fileName); err == nil && !force {
+		return fmt.Errorf("%s already exists. Use --force to overwrite it", fileName)
+	}
+
+	opt, fromService, err := askInitOption(dekopinCmd, newPrompter(cmd.InOrStdin(), cmd.OutOrStdout()))
+	if err != nil {
+		return err
+	}
+
+	ctx = SetCmdOption(ctx, opt)
+	out, err := initConfig(ctx, gc, fromService)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(fileName, out, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", fileName, err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", fileName)
+	return nil
+}
+
+// askInitOption takes the options from flags and asks for the missing ones.
+func askInitOption(dekopinCmd DekopinCommand, p *prompter) (*CmdOption, bool, error) {
+	project, err := dekopinCmd.GetProjectByFlag()
+	if err != nil {
+		return nil, false, err
+	}
+
+	region, err := dekopinCmd.GetRegionByFlag()
+	if err != nil {
+		return nil, false, err
+	}
+
+	service, err := dekopinCmd.GetFromServiceByFlag()
+	if err != nil {
+		return nil, false, err
+	}
+	fromService := service != ""
+	if !fromService {
+		service, err = dekopinCmd.GetServiceByFlag()
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	runner, err := dekopinCmd.GetRunnerByFlag()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if project, err = p.ask("GCP project id", project); err != nil {
+		return nil, false, err
+	}
+	if region, err = p.ask("Region", region); err != nil {
+		return nil, false, err
+	}
+	if service, err = p.ask("Service name", service); err != nil {
+		return nil, false, err
+	}
+	if runner, err = p.ask("Runner ("+strings.Join(ValidRunners, ", ")+")", runner); err != nil {
+		return nil, false, err
+	}
+
+	opt := &CmdOption{
+		Project: project,
+		Region:  region,
+		Service: service,
+		Runner:  runner,
+	}
+	if err := opt.Validate(); err != nil {
+		return nil, false, err
+	}
+
+	return opt, fromService, nil
+}
+
+// initConfig checks that the service exists and renders the configuration file.
+// With fromService the live container settings are written as the spec.
+func initConfig(ctx context.Context, gc GCloud, fromService bool) ([]byte, error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("service %s was not found in project %s, region %s: %w", opt.Service, opt.Project, opt.Region, err)
+	}
+
+	config := &DekopinConfig{
+		Project: opt.Project,
+		Region:  opt.Region,
+		Service: opt.Service,
+		Runner:  opt.Runner,
+	}
+
+	var buf bytes.Buffer
+	if fromService {
+		var revision *runpb.Revision
+		if service.GetLatestReadyRevision() != "" {
+			revision, err = gc.GetRevision(ctx, path.Base(service.GetLatestReadyRevision()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get latest revision: %w", err)
+			}
+		}
+		config.Spec = SpecFromLive(service, revision)
+		writeTrafficComment(&buf, service)
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render configuration: %w", err)
+	}
+	buf.Write(out)
+
+	return buf.Bytes(), nil
+}
+
+// SpecFromLive builds the spec that reproduces the live service and its latest revision.
+func SpecFromLive(service *runpb.Service, revision *runpb.Revision) *ServiceSpec {
+	spec := &ServiceSpec{}
+	if containers := revision.GetContainers(); len(containers) > 0 {
+		c := containers[0]
+		for _, env := range c.GetEnv() {
+			if env.GetValueSource() != nil {
+				continue
+			}
+			if spec.Env == nil {
+				spec.Env = map[string]string{}
+			}
+			spec.Env[env.GetName()] = env.GetValue()
+		}
+		spec.CPU = c.GetResources().GetLimits()["cpu"]
+		spec.Memory = c.GetResources().GetLimits()["memory"]
+	}
+
+	for _, ref := range RevisionSecretRefs(revision.GetContainers(), revision.GetVolumes()) {
+		if spec.Secrets == nil {
+			spec.Secrets = map[string]string{}
+		}
+		spec.Secrets[ref.Target] = ref.Secret + ":" + ref.Version
+	}
+
+	if revision != nil {
+		// 0 is what Cloud Run reports for unset values, and not a valid concurrency.
+		if concurrency := int(revision.GetMaxInstanceRequestConcurrency()); concurrency > 0 {
+			spec.Concurrency = &concurrency
+		}
+		if minInstances := int(revision.GetScaling().GetMinInstanceCount()); minInstances > 0 {
+			spec.MinInstances = &minInstances
+		}
+		if maxInstances := int(revision.GetScaling().GetMaxInstanceCount()); maxInstances > 0 {
+			spec.MaxInstances = &maxInstances
+		}
+		spec.ServiceAccount = revision.GetServiceAccount()
+		if connector := revision.GetVpcAccess().GetConnector(); connector != "" {
+			spec.VPCConnector = path.Base(connector)
+		}
+	}
+
+	for k, v := range service.GetLabels() {
+		if slices.ContainsFunc(systemLabelPrefixes, func(p string) bool { return strings.HasPrefix(k, p) }) {
+			continue
+		}
+		if spec.Labels == nil {
+			spec.Labels = map[string]string{}
+		}
+		spec.Labels[k] = v
+	}
+
+	return spec
+}
+
+// writeTrafficComment records the live traffic and tags, which dekopin.yml has no setting for.
+func writeTrafficComment(w io.Writer, service *runpb.Service) {
+	fmt.Fprintf(w, "# Imported from %s\n", service.GetName())
+	fmt.Fprintln(w, "# Live traffic:")
+	for _, t := range service.GetTrafficStatuses() {
+		revision := t.GetRevision()
+		if revision == "" {
+			revision = "LATEST"
+		}
+		line := "#   " + revision + " " + strconv.Itoa(int(t.GetPercent())) + "%"
+		if t.GetTag() != "" {
+			line += " (tag: " + t.GetTag() + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out}
+}
+
+// ask returns value as is when it is already set, otherwise it reads an answer.
+func (p *prompter) ask(question string, value string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+
+	fmt.Fprintf(p.out, "%s: ", question)
+	answer, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", fmt.Errorf("%s is required", strings.ToLower(question))
+	}
+
+	return answer, nil
+}
@@ -0,0 +1,91 @@
+package dekopin_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/iwashi623/dekopin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecFromLive(t *testing.T) {
+	type TestResult struct {
+		Spec *dekopin.ServiceSpec
+	}
+
+	type ArrangeResult struct {
+		service  *runpb.Service
+		revision *runpb.Revision
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_imports_container_settings_and_user_labels": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					service: &runpb.Service{
+						Labels: map[string]string{"team": "backend", "goog-managed-by": "cloudrun"},
+					},
+					revision: &runpb.Revision{
+						Containers: []*runpb.Container{{
+							Env: []*runpb.EnvVar{
+								{Name: "APP_ENV", Values: &runpb.EnvVar_Value{Value: "production"}},
+								{Name: "DB_PASSWORD", Values: &runpb.EnvVar_ValueSource{ValueSource: &runpb.EnvVarSource{
+									SecretKeyRef: &runpb.SecretKeySelector{Secret: "db-password", Version: "3"},
+								}}},
+							},
+							Resources: &runpb.ResourceRequirements{Limits: map[string]string{"cpu": "1", "memory": "512Mi"}},
+						}},
+						Scaling:                       &runpb.RevisionScaling{MinInstanceCount: 1, MaxInstanceCount: 10},
+						MaxInstanceRequestConcurrency: 80,
+						ServiceAccount:                "app@project.iam.gserviceaccount.com",
+						VpcAccess:                     &runpb.VpcAccess{Connector: "projects/p/locations/r/connectors/vpc"},
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, &dekopin.ServiceSpec{
+					Env:            map[string]string{"APP_ENV": "production"},
+					CPU:            "1",
+					Memory:         "512Mi",
+					Concurrency:    lo.ToPtr(80),
+					MinInstances:   lo.ToPtr(1),
+					MaxInstances:   lo.ToPtr(10),
+					ServiceAccount: "app@project.iam.gserviceaccount.com",
+					VPCConnector:   "vpc",
+					Labels:         map[string]string{"team": "backend"},
+					Secrets:        map[string]string{"DB_PASSWORD": "db-password:3"},
+				}, result.Spec)
+			},
+		},
+		"success_zero_values_are_left_unset": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					service:  &runpb.Service{},
+					revision: &runpb.Revision{Scaling: &runpb.RevisionScaling{}},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, &dekopin.ServiceSpec{}, result.Spec)
+				assert.NoError(t, result.Spec.Validate())
+			},
+		},
+		"success_empty_spec_without_revision": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{service: &runpb.Service{}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, &dekopin.ServiceSpec{}, result.Spec)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Spec: dekopin.SpecFromLive(ar.service, ar.revision),
+			})
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package dekopin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	NOTIFICATION_SLACK   = "slack"
+	NOTIFICATION_WEBHOOK = "webhook"
+
+	EVENT_STARTED     = "started"
+	EVENT_SUCCEEDED   = "succeeded"
+	EVENT_FAILED      = "failed"
+	EVENT_ROLLED_BACK = "rolled-back"
+
+	DEFAULT_NOTIFICATION_TIMEOUT  = 10 * time.Second
+	DEFAULT_NOTIFICATION_INTERVAL = 1 * time.Second
+	MAX_NOTIFICATION_BODY_SIZE    = 1 << 10
+)
+
+var NotificationEvents = []string{EVENT_STARTED, EVENT_SUCCEEDED, EVENT_FAILED, EVENT_ROLLED_BACK}
+
+// Notification sends the events of the mutating commands to a Slack incoming webhook or a JSON webhook.
+type Notification struct {
+	Type   string   `yaml:"type"` // slack or webhook
+	URL    string   `yaml:"url"`
+	Events []string `yaml:"events,omitempty"` // Empty means every event
+	// Template is the Slack message text, or the webhook body. It may use {{event}}, {{command}},
+	// {{project}}, {{region}}, {{service}}, {{environment}}, {{actor}}, {{image}}, {{tag}},
+	// {{tag_url}}, {{revision}}, {{url}}, {{time}} and {{error}}.
+	Template string            `yaml:"template,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Timeout  time.Duration     `yaml:"timeout,omitempty"`
+	Retries  int               `yaml:"retries,omitempty"`
+	Interval time.Duration     `yaml:"interval,omitempty"` // Wait between retries
+}
+
+func validateNotifications(notifications []Notification) error {
+	errs := []error{}
+	for i, n := range notifications {
+		if n.Type != NOTIFICATION_SLACK && n.Type != NOTIFICATION_WEBHOOK {
+			errs = append(errs, fmt.Errorf("notifications[%d]: invalid type %q. Valid values: slack, webhook", i, n.Type))
+		}
+		if !strings.HasPrefix(n.URL, "https://") && !strings.HasPrefix(n.URL, "http://") {
+			errs = append(errs, fmt.Errorf("notifications[%d]: url must be an http or https URL", i))
+		}
+		for _, event := range n.Events {
+			if !slices.Contains(NotificationEvents, event) {
+				errs = append(errs, fmt.Errorf("notifications[%d]: unknown event %q. Valid values: %s", i, event, strings.Join(NotificationEvents, ", ")))
+			}
+		}
+		if n.Timeout < 0 || n.Interval < 0 || n.Retries < 0 {
+			errs = append(errs, fmt.Errorf("notifications[%d]: timeout, interval and retries must not be negative", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Wants reports whether the notification is sent for event.
+func (n Notification) Wants(event string) bool {
+	return len(n.Events) == 0 || slices.Contains(n.Events, event)
+}
+
+// withNotifications notifies the start and the result of fn by a mutating command.
+func withNotifications(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		dekopinCmd, err := GetDekopinCommand(ctx)
+		if err != nil || !slices.Contains(HookCommands, dekopinCmd.CommandName()) {
+			return fn(ctx)
+		}
+
+		Notify(ctx, EVENT_STARTED, nil)
+		if err := fn(ctx); err != nil {
+			Notify(ctx, EVENT_FAILED, err)
+			return err
+		}
+
+		Notify(ctx, EVENT_SUCCEEDED, nil)
+		return nil
+	}
+}
+
+// Notify sends event to the configured notifications that want it. A notification that cannot be
+// delivered is reported but never fails the command.
+func Notify(ctx context.Context, event string, cause error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return
+	}
+
+	notifications := slices.DeleteFunc(slices.Clone(opt.Notifications), func(n Notification) bool {
+		return !n.Wants(event)
+	})
+	if len(notifications) == 0 {
+		return
+	}
+
+	command := ""
+	if dekopinCmd, err := GetDekopinCommand(ctx); err == nil {
+		command = dekopinCmd.CommandName()
+	}
+
+	vars := NotificationVars(commandVars(ctx, command), event, cause)
+	for _, n := range notifications {
+		if err := SendNotification(ctx, GetHTTPClient(ctx), n, vars); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to send %s notification: %s\n", n.Type, err)
+		}
+	}
+}
+
+// NotificationVars returns the template values of an event, keyed by placeholder name.
+func NotificationVars(commandVars map[string]string, event string, cause error) map[string]string {
+	vars := map[string]string{}
+	for k, v := range commandVars {
+		vars[strings.ToLower(k)] = v
+	}
+	vars["event"] = event
+	vars["actor"] = policyActor()
+	vars["time"] = time.Now().UTC().Format(time.RFC3339)
+	if cause != nil {
+		vars["error"] = cause.Error()
+	}
+	return vars
+}
+
+// SendNotification posts the message of n, retrying on network errors and 429 or 5xx responses.
+func SendNotification(ctx context.Context, client *http.Client, n Notification, vars map[string]string) error {
+	body, err := notificationBody(n, vars)
+	if err != nil {
+		return err
+	}
+
+	interval := n.Interval
+	if interval == 0 {
+		interval = DEFAULT_NOTIFICATION_INTERVAL
+	}
+
+	// Failures are notified too, so the command deadline must not cut the delivery short.
+	ctx = context.WithoutCancel(ctx)
+
+	for attempt := 0; ; attempt++ {
+		retryable, err := postNotification(ctx, client, n, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= n.Retries {
+			return err
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func postNotification(ctx context.Context, client *http.Client, n Notification, body []byte) (bool, error) {
+	timeout := n.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_NOTIFICATION_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error of the client repeats the URL, which must not be logged.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, fmt.Errorf("%s: %w", redactURL(n.URL), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, MAX_NOTIFICATION_BODY_SIZE))
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("%s returned %s: %s", redactURL(n.URL), resp.Status, strings.TrimSpace(string(message)))
+}
+
+// notificationBody renders the template. Webhook templates are JSON, so the values are escaped for
+// use inside JSON strings; a Slack template is the message text.
+func notificationBody(n Notification, vars map[string]string) ([]byte, error) {
+	if n.Type == NOTIFICATION_SLACK {
+		text := n.Template
+		if text == "" {
+			text = defaultSlackText(vars)
+		}
+		return json.Marshal(map[string]string{"text": placeholders(vars, func(v string) string { return v }).Replace(text)})
+	}
+
+	if n.Template == "" {
+		return json.Marshal(vars)
+	}
+
+	body := placeholders(vars, func(v string) string {
+		quoted, _ := json.Marshal(v)
+		return string(quoted[1 : len(quoted)-1])
+	}).Replace(n.Template)
+	if !json.Valid([]byte(body)) {
+		return nil, fmt.Errorf("webhook template does not render valid JSON")
+	}
+	return []byte(body), nil
+}
+
+func placeholders(vars map[string]string, escape func(string) string) *strings.Replacer {
+	oldnew := []string{}
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		oldnew = append(oldnew, "{{"+k+"}}", escape(vars[k]))
+	}
+	// Placeholders without a value render empty.
+	for _, k := range []string{"event", "command", "project", "region", "service", "environment", "actor", "image", "tag", "tag_url", "revision", "url", "time", "error"} {
+		if _, ok := vars[k]; !ok {
+			oldnew = append(oldnew, "{{"+k+"}}", "")
+		}
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+func defaultSlackText(vars map[string]string) string {
+	text := fmt.Sprintf("dekopin %s %s: %s in %s", vars["command"], vars["event"], vars["service"], vars["region"])
+	if vars["actor"] != "" {
+		text += " by " + vars["actor"]
+	}
+	for _, k := range []string{"revision", "tag", "image", "error"} {
+		if vars[k] != "" {
+			text += fmt.Sprintf("\n%s: %s", k, vars[k])
+		}
+	}
+	return text
+}
+
+// redactURL drops the path of a webhook URL, which usually carries its secret.
+func redactURL(rawURL string) string {
+	scheme, rest, _ := strings.Cut(rawURL, "://")
+	host, _, _ := strings.Cut(rest, "/")
+	return scheme + "://" + host
+}
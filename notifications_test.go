@@ -0,0 +1,135 @@
+package dekopin_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendNotification(t *testing.T) {
+	type TestResult struct {
+		Err      error
+		Bodies   []string
+		Requests int
+	}
+
+	type ArrangeResult struct {
+		notification dekopin.Notification
+		statuses     []int // Status of each request, the last one repeats
+	}
+
+	vars := dekopin.NotificationVars(map[string]string{
+		"COMMAND":  "deploy",
+		"SERVICE":  "my-service",
+		"REGION":   "asia-northeast1",
+		"REVISION": "my-service-abc1234",
+	}, dekopin.EVENT_FAILED, errors.New(`smoke check "/healthz" failed`))
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_slack_template": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					notification: dekopin.Notification{Type: "slack", Template: "{{service}} {{event}}: {{error}}"},
+					statuses:     []int{http.StatusOK},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{`{"text":"my-service failed: smoke check \"/healthz\" failed"}`}, result.Bodies)
+			},
+		},
+		"success_webhook_template_escapes_values": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					notification: dekopin.Notification{Type: "webhook", Template: `{"status": "{{event}}", "reason": "{{error}}", "tag": "{{tag}}"}`},
+					statuses:     []int{http.StatusNoContent},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{`{"status": "failed", "reason": "smoke check \"/healthz\" failed", "tag": ""}`}, result.Bodies)
+			},
+		},
+		"success_retries_server_errors": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					notification: dekopin.Notification{Type: "webhook", Retries: 2, Interval: time.Millisecond},
+					statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, 3, result.Requests)
+				assert.Contains(t, result.Bodies[0], `"revision":"my-service-abc1234"`)
+			},
+		},
+		"error_retries_used_up": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					notification: dekopin.Notification{Type: "webhook", Retries: 1, Interval: time.Millisecond},
+					statuses:     []int{http.StatusBadGateway},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "502 Bad Gateway")
+				assert.Equal(t, 2, result.Requests)
+			},
+		},
+		"error_client_error_is_not_retried": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					notification: dekopin.Notification{Type: "slack", Retries: 3, Interval: time.Millisecond},
+					statuses:     []int{http.StatusNotFound},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "404 Not Found")
+				assert.NotContains(t, result.Err.Error(), "/services/secret")
+				assert.Equal(t, 1, result.Requests)
+			},
+		},
+		"error_template_is_not_json": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					notification: dekopin.Notification{Type: "webhook", Template: `{"status": {{event}}}`},
+					statuses:     []int{http.StatusOK},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "valid JSON")
+				assert.Equal(t, 0, result.Requests)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+
+			var mu sync.Mutex
+			result := TestResult{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				body, _ := io.ReadAll(r.Body)
+				result.Bodies = append(result.Bodies, string(body))
+				status := args.statuses[min(result.Requests, len(args.statuses)-1)]
+				result.Requests++
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			args.notification.URL = server.URL + "/services/secret"
+			result.Err = dekopin.SendNotification(context.Background(), server.Client(), args.notification, vars)
+			tc.Assert(t, args, result)
+		})
+	}
+}
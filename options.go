@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -13,6 +14,47 @@ type CmdOption struct {
 	Region  string
 	Service string
 	Runner  string
+
+	// Regions is set when the service is deployed to several regions.
+	// Commands that support it run once per region with Region set accordingly.
+	Regions []string
+	Waves   bool
+
+	// Services is set when dekopin.yml declares several services.
+	// Commands that support it run once per service.
+	Services    []ServiceConfig
+	Concurrency int
+
+	// Spec is applied to every revision created by deploy and create-revision.
+	Spec *ServiceSpec
+
+	// Environment names the deployment environment, e.g. production. Policies are evaluated against it.
+	Environment  string
+	SecretPolicy *SecretPolicy
+	ImagePolicy  *ImagePolicy
+
+	SignaturePolicy *SignaturePolicy
+
+	// Smoke checks run against the tag URL before traffic is moved to a new revision.
+	Smoke *SmokeConfig
+	// Watch is polled after st-deploy and sr-deploy with --watch.
+	Watch *WatchConfig
+
+	// Hooks are keyed by command name, e.g. deploy or bluegreen promote.
+	Hooks map[string]*CommandHooks
+
+	// ProtectedTags are tag names or globs that are never removed, and only reassigned with --force.
+	ProtectedTags []string
+
+	// Freeze windows block the commands that change traffic unless --emergency is given.
+	Freeze []FreezeWindow
+	// History is where the mutating commands are recorded. Nil keeps it on the service annotations.
+	History *HistoryConfig
+
+	// Notifications are sent when a mutating command starts, succeeds, fails or is rolled back.
+	Notifications []Notification
+	// GitHub reports the commands to the repository when the runner is GitHub Actions.
+	GitHub *GitHubConfig
 }
 
 type cmdOptionKey struct{}
@@ -49,6 +91,9 @@ func NewCmdOption(ctx context.Context, config *DekopinConfig, cmd *cobra.Command
 	}
 	if region == "" && config != nil {
 		region = config.Region
+		if len(config.Regions) > 0 {
+			region = strings.Join(config.Regions, ",")
+		}
 	}
 
 	service, err := dekopinCmd.GetServiceByFlag()
@@ -59,6 +104,14 @@ func NewCmdOption(ctx context.Context, config *DekopinConfig, cmd *cobra.Command
 		service = config.Service
 	}
 
+	environment, err := dekopinCmd.GetEnvironmentByFlag()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment flag: %w", err)
+	}
+	if environment == "" && config != nil {
+		environment = config.Environment
+	}
+
 	runner, err := dekopinCmd.GetRunnerByFlag()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get runner flag: %w", err)
@@ -68,10 +121,42 @@ func NewCmdOption(ctx context.Context, config *DekopinConfig, cmd *cobra.Command
 	}
 
 	option := &CmdOption{
-		Project: project,
-		Region:  region,
-		Service: service,
-		Runner:  runner,
+		Project:     project,
+		Region:      region,
+		Service:     service,
+		Runner:      runner,
+		Environment: environment,
+	}
+
+	if regions := splitList(region); len(regions) > 1 {
+		option.Region = ""
+		option.Regions = regions
+	}
+
+	if config != nil {
+		option.Waves = config.Waves
+		option.Spec = config.Spec
+		option.SecretPolicy = config.SecretPolicy
+		option.ImagePolicy = config.ImagePolicy
+		option.SignaturePolicy = config.SignaturePolicy
+		option.Smoke = config.Smoke
+		option.Watch = config.Watch
+		option.Hooks = config.Hooks
+		option.ProtectedTags = config.ProtectedTags
+		option.Freeze = config.Freeze
+		option.History = config.History
+		option.Notifications = config.Notifications
+		option.GitHub = config.GitHub
+	}
+
+	if config != nil && len(config.Services) > 0 {
+		services, err := selectServices(config.Services, service, option.Region)
+		if err != nil {
+			return nil, err
+		}
+		option.Service = ""
+		option.Services = services
+		option.Concurrency = config.Concurrency
 	}
 
 	if err := option.Validate(); err != nil {
@@ -81,14 +166,92 @@ func NewCmdOption(ctx context.Context, config *DekopinConfig, cmd *cobra.Command
 	return option, nil
 }
 
+// selectServices returns the services named in the comma separated selection,
+// or every service when the selection is empty.
+func selectServices(services []ServiceConfig, selection string, defaultRegion string) ([]ServiceConfig, error) {
+	selected := services
+	if selection != "" {
+		selected = []ServiceConfig{}
+		for _, name := range splitList(selection) {
+			idx := slices.IndexFunc(services, func(s ServiceConfig) bool { return s.Name == name })
+			if idx < 0 {
+				return nil, fmt.Errorf("service %s is not defined in the configuration file", name)
+			}
+			selected = append(selected, services[idx])
+		}
+	}
+
+	result := make([]ServiceConfig, 0, len(selected))
+	for _, s := range selected {
+		if s.Region == "" {
+			s.Region = defaultRegion
+		}
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// TargetRegions returns every region the command operates on.
+func (c *CmdOption) TargetRegions() []string {
+	if len(c.Regions) > 0 {
+		return c.Regions
+	}
+	return []string{c.Region}
+}
+
+// splitList splits a comma separated flag or config value.
+func splitList(value string) []string {
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 func (c *CmdOption) Validate() error {
-	if c.Project == "" || c.Region == "" || c.Service == "" || c.Runner == "" {
+	if len(c.Services) > 0 {
+		if c.Project == "" || c.Runner == "" {
+			return fmt.Errorf("project and runner are required")
+		}
+		for _, s := range c.Services {
+			if s.Region == "" && len(c.Regions) == 0 {
+				return fmt.Errorf("region is required for service %s", s.Name)
+			}
+		}
+	} else if c.Project == "" || (c.Region == "" && len(c.Regions) == 0) || c.Service == "" || c.Runner == "" {
 		return fmt.Errorf("project, region, service, and runner are required")
 	}
 
-	if !slices.Contains(ValidRunners, c.Runner) {
-		return fmt.Errorf("invalid runner type. Valid values: github-actions, cloud-build, local")
-	}
+	// The values themselves are checked like the configuration file, as they mostly come from it.
+	return ValidateConfig(c.Config())
+}
 
-	return nil
+// Config returns the options as a configuration.
+func (c *CmdOption) Config() *DekopinConfig {
+	return &DekopinConfig{
+		Project:         c.Project,
+		Region:          c.Region,
+		Regions:         c.Regions,
+		Waves:           c.Waves,
+		Service:         c.Service,
+		Runner:          c.Runner,
+		Services:        c.Services,
+		Concurrency:     c.Concurrency,
+		Spec:            c.Spec,
+		Environment:     c.Environment,
+		SecretPolicy:    c.SecretPolicy,
+		ImagePolicy:     c.ImagePolicy,
+		SignaturePolicy: c.SignaturePolicy,
+		Smoke:           c.Smoke,
+		Watch:           c.Watch,
+		Hooks:           c.Hooks,
+		ProtectedTags:   c.ProtectedTags,
+		Freeze:          c.Freeze,
+		History:         c.History,
+		Notifications:   c.Notifications,
+		GitHub:          c.GitHub,
+	}
 }
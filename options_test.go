@@ -89,6 +89,20 @@ func TestCmdOption_Validate(t *testing.T) {
 				assert.Error(t, result.Err)
 			},
 		},
+		"error_invalid_settings_with_services": {
+			Arrange: func() ArrangeResult {
+				option := makeOption()
+				option.Service = ""
+				option.Services = []dekopin.ServiceConfig{{Name: "api", Region: "us-central1"}}
+				option.Freeze = []dekopin.FreezeWindow{{Name: "weekend", Cron: "* * * * 8"}}
+				return ArrangeResult{
+					option: option,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "invalid cron")
+			},
+		},
 		"error_invalid_runner_value": {
 			Arrange: func() ArrangeResult {
 				option := makeOption()
@@ -281,6 +295,101 @@ func TestNewCmdOption(t *testing.T) {
 				assert.Equal(t, assertArgs.runner, result.Option.Runner)
 			},
 		},
+		"success_services_in_config_selected_by_service_flag": {
+			Arrange: func() ArrangeResult {
+				cmd := &cobra.Command{}
+				cmd.Flags().String("project", "", "")
+				cmd.Flags().String("region", "", "")
+				cmd.Flags().String("service", "api, worker", "")
+				cmd.Flags().String("runner", "", "")
+
+				config := &dekopin.DekopinConfig{
+					Project: "config-project",
+					Region:  "config-region",
+					Runner:  dekopin.RUNNER_LOCAL,
+					Services: []dekopin.ServiceConfig{
+						{Name: "api", Region: "asia-northeast1"},
+						{Name: "web"},
+						{Name: "worker"},
+					},
+				}
+
+				ctx := dekopin.SetDekopinCommand(context.Background(), dekopin.NewDekopinCommand(cmd))
+
+				return ArrangeResult{
+					ctx:    ctx,
+					config: config,
+					cmd:    cmd,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, "", result.Option.Service)
+				assert.Equal(t, []dekopin.ServiceConfig{
+					{Name: "api", Region: "asia-northeast1"},
+					{Name: "worker", Region: "config-region"},
+				}, result.Option.Services)
+			},
+		},
+		"error_service_flag_selects_unknown_service": {
+			Arrange: func() ArrangeResult {
+				cmd := &cobra.Command{}
+				cmd.Flags().String("project", "", "")
+				cmd.Flags().String("region", "", "")
+				cmd.Flags().String("service", "unknown", "")
+				cmd.Flags().String("runner", "", "")
+
+				config := &dekopin.DekopinConfig{
+					Project:  "config-project",
+					Region:   "config-region",
+					Runner:   dekopin.RUNNER_LOCAL,
+					Services: []dekopin.ServiceConfig{{Name: "api"}},
+				}
+
+				ctx := dekopin.SetDekopinCommand(context.Background(), dekopin.NewDekopinCommand(cmd))
+
+				return ArrangeResult{
+					ctx:    ctx,
+					config: config,
+					cmd:    cmd,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Error(t, result.Err)
+				assert.Nil(t, result.Option)
+			},
+		},
+		"success_regions_in_config": {
+			Arrange: func() ArrangeResult {
+				cmd := &cobra.Command{}
+				cmd.Flags().String("project", "", "")
+				cmd.Flags().String("region", "", "")
+				cmd.Flags().String("service", "", "")
+				cmd.Flags().String("runner", "", "")
+
+				config := &dekopin.DekopinConfig{
+					Project: "config-project",
+					Regions: []string{"asia-northeast1", "us-central1"},
+					Service: "config-service",
+					Runner:  dekopin.RUNNER_LOCAL,
+					Waves:   true,
+				}
+
+				ctx := dekopin.SetDekopinCommand(context.Background(), dekopin.NewDekopinCommand(cmd))
+
+				return ArrangeResult{
+					ctx:    ctx,
+					config: config,
+					cmd:    cmd,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, "", result.Option.Region)
+				assert.Equal(t, []string{"asia-northeast1", "us-central1"}, result.Option.Regions)
+				assert.True(t, result.Option.Waves)
+			},
+		},
 		"error_missing_required_values": {
 			Arrange: func() ArrangeResult {
 				cmd := &cobra.Command{}
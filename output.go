@@ -0,0 +1,88 @@
+package dekopin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+type outputKey struct{}
+
+type output struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// WithOutput returns a context whose commands print to stdout and stderr instead of the process streams.
+func WithOutput(ctx context.Context, stdout io.Writer, stderr io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, &output{stdout: stdout, stderr: stderr})
+}
+
+// Stdout returns where the commands running with ctx print their output.
+func Stdout(ctx context.Context) io.Writer {
+	if out, ok := ctx.Value(outputKey{}).(*output); ok {
+		return out.stdout
+	}
+	return os.Stdout
+}
+
+// Stderr returns where the commands running with ctx print their errors and progress.
+func Stderr(ctx context.Context) io.Writer {
+	if out, ok := ctx.Value(outputKey{}).(*output); ok {
+		return out.stderr
+	}
+	return os.Stderr
+}
+
+// prefixWriter writes complete lines only, each starting with prefix, so the output of
+// commands running concurrently does not interleave within a line.
+type prefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+
+	return len(b), nil
+}
+
+// Flush writes the last line when it did not end with a newline.
+func (p *prefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	// A single write keeps the line together on the shared stream.
+	_, err := p.w.Write(append([]byte(p.prefix), line...))
+	return err
+}
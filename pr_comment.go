@@ -0,0 +1,299 @@
+package dekopin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// PREVIEW_COMMENT_MARKER starts the hidden line that identifies the preview comment and
+	// carries its rows, so that every run can merge its own row into the comment.
+	PREVIEW_COMMENT_MARKER = "<!-- dekopin-preview "
+	PREVIEW_COMMENT_TITLE  = "### Cloud Run preview"
+
+	GITHUB_COMMENTS_PER_PAGE = 100
+)
+
+// previewCommentCommands publish a tag that the pull request comment lists.
+var previewCommentCommands = []string{"deploy", "create-tag", "st-deploy", "bluegreen deploy"}
+
+// prCommentMu serializes the services of a command, which update the same comment concurrently.
+var prCommentMu sync.Mutex
+
+// GitHubComment is the part of an issue comment dekopin reads back.
+type GitHubComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+func (c *GitHubClient) ListIssueComments(ctx context.Context, number int) ([]GitHubComment, error) {
+	comments := []GitHubComment{}
+	for page := 1; ; page++ {
+		batch := []GitHubComment{}
+		endpoint := fmt.Sprintf("issues/%d/comments?per_page=%d&page=%d", number, GITHUB_COMMENTS_PER_PAGE, page)
+		if err := c.do(ctx, http.MethodGet, endpoint, nil, &batch); err != nil {
+			return nil, fmt.Errorf("failed to list comments: %w", err)
+		}
+		comments = append(comments, batch...)
+		if len(batch) < GITHUB_COMMENTS_PER_PAGE {
+			return comments, nil
+		}
+	}
+}
+
+func (c *GitHubClient) CreateIssueComment(ctx context.Context, number int, body string) error {
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("issues/%d/comments", number), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return nil
+}
+
+func (c *GitHubClient) UpdateIssueComment(ctx context.Context, id int64, body string) error {
+	if err := c.do(ctx, http.MethodPatch, "issues/comments/"+strconv.FormatInt(id, 10), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to update comment %d: %w", id, err)
+	}
+	return nil
+}
+
+// PullRequestNumber returns the number of a pull request ref, refs/pull/<number>/merge.
+func PullRequestNumber(ref string) (int, bool) {
+	rest, ok := strings.CutPrefix(ref, "refs/pull/")
+	if !ok {
+		return 0, false
+	}
+	number, _, _ := strings.Cut(rest, "/")
+	n, err := strconv.Atoi(number)
+	return n, err == nil && n > 0
+}
+
+// PreviewRow is one tag in the preview comment.
+type PreviewRow struct {
+	Service  string `json:"service"`
+	Region   string `json:"region"`
+	Tag      string `json:"tag"`
+	URL      string `json:"url,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Traffic  int32  `json:"traffic"`
+	Image    string `json:"image,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Removed  bool   `json:"removed,omitempty"`
+}
+
+// PreviewComment is the sticky pull request comment listing the preview tags.
+type PreviewComment struct {
+	Rows []PreviewRow `json:"rows"`
+}
+
+// ParsePreviewComment reads the rows of a comment written by Render. It reports false for other comments.
+func ParsePreviewComment(body string) (*PreviewComment, bool) {
+	line, _, _ := strings.Cut(body, "\n")
+	data, ok := strings.CutPrefix(line, PREVIEW_COMMENT_MARKER)
+	if !ok {
+		return nil, false
+	}
+
+	comment := &PreviewComment{}
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(data, " -->")), comment); err != nil {
+		// A comment with a damaged marker is taken over with no rows.
+		return &PreviewComment{}, true
+	}
+	return comment, true
+}
+
+// Upsert replaces the row of the same service, region and tag, or adds it.
+func (p *PreviewComment) Upsert(row PreviewRow) {
+	i := slices.IndexFunc(p.Rows, func(r PreviewRow) bool {
+		return r.Service == row.Service && r.Region == row.Region && r.Tag == row.Tag
+	})
+	if i < 0 {
+		p.Rows = append(p.Rows, row)
+		return
+	}
+	p.Rows[i] = row
+}
+
+// MarkRemoved marks the rows of a tag removed. It reports whether any row matched.
+func (p *PreviewComment) MarkRemoved(service string, region string, tag string) bool {
+	found := false
+	for i, r := range p.Rows {
+		if r.Service == service && r.Region == region && r.Tag == tag {
+			p.Rows[i].Removed = true
+			found = true
+		}
+	}
+	return found
+}
+
+// Render returns the comment body: the hidden marker with the rows, and the table.
+func (p *PreviewComment) Render() (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode preview comment: %w", err)
+	}
+
+	var b strings.Builder
+	// json.Marshal escapes > so the rows cannot end the HTML comment early.
+	fmt.Fprintf(&b, "%s%s -->\n", PREVIEW_COMMENT_MARKER, data)
+	fmt.Fprintf(&b, "%s\n\n", PREVIEW_COMMENT_TITLE)
+	fmt.Fprintln(&b, "| Service | Region | Tag | URL | Revision | Traffic | Image | Commit |")
+	fmt.Fprintln(&b, "|---|---|---|---|---|---|---|---|")
+	for _, r := range p.Rows {
+		url, traffic := r.URL, fmt.Sprintf("%d%%", r.Traffic)
+		if r.Removed {
+			url, traffic = "removed", "-"
+		}
+		fmt.Fprintf(&b, "| %s | %s | `%s` | %s | `%s` | %s | `%s` | %s |\n", r.Service, r.Region, r.Tag, url, r.Revision, traffic, r.Image, r.Commit)
+	}
+
+	return b.String(), nil
+}
+
+// withPullRequestComment keeps the preview comment of the pull request up to date when
+// github.pr_comments is on and the workflow runs for a pull request. GitHub errors are reported
+// but never fail the command.
+func withPullRequestComment(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		dekopinCmd, err := GetDekopinCommand(ctx)
+		if err != nil {
+			return fn(ctx)
+		}
+
+		opt, err := GetCmdOption(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get cmdOption: %w", err)
+		}
+
+		command := dekopinCmd.CommandName()
+		tag := getHookVars(ctx)["TAG"]
+		number, isPullRequest := PullRequestNumber(os.Getenv(ENV_GITHUB_REF))
+		if opt.GitHub == nil || !opt.GitHub.PRComments || opt.Runner != RUNNER_GITHUB_ACTIONS || !isPullRequest || tag == "" ||
+			(command != "remove-tag" && !slices.Contains(previewCommentCommands, command)) {
+			return fn(ctx)
+		}
+
+		if err := fn(ctx); err != nil {
+			return err
+		}
+
+		client, err := NewGitHubClient()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: the pull request comment is skipped: %s\n", err)
+			return nil
+		}
+
+		update := func(p *PreviewComment) bool {
+			return p.MarkRemoved(opt.Service, opt.Region, tag)
+		}
+		if command != "remove-tag" {
+			row, err := previewRow(ctx, tag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: the pull request comment is skipped: %s\n", err)
+				return nil
+			}
+			update = func(p *PreviewComment) bool {
+				p.Upsert(row)
+				return true
+			}
+		}
+
+		if err := UpdatePreviewComment(ctx, client, number, update); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to update the pull request comment: %s\n", err)
+		}
+		return nil
+	}
+}
+
+// UpdatePreviewComment applies update to the preview comment of the pull request, creating the
+// comment on the first preview. Nothing is written when update reports no change.
+func UpdatePreviewComment(ctx context.Context, client *GitHubClient, number int, update func(p *PreviewComment) bool) error {
+	prCommentMu.Lock()
+	defer prCommentMu.Unlock()
+
+	comments, err := client.ListIssueComments(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	var existing *GitHubComment
+	preview := &PreviewComment{}
+	for _, c := range comments {
+		if p, ok := ParsePreviewComment(c.Body); ok {
+			existing, preview = &c, p
+			break
+		}
+	}
+
+	if !update(preview) {
+		return nil
+	}
+
+	body, err := preview.Render()
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		return client.CreateIssueComment(ctx, number, body)
+	}
+	return client.UpdateIssueComment(ctx, existing.ID, body)
+}
+
+// previewRow describes the tag on the service of ctx.
+func previewRow(ctx context.Context, tag string) (PreviewRow, error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return PreviewRow{}, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return PreviewRow{}, fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return PreviewRow{}, err
+	}
+
+	row := PreviewRow{Service: opt.Service, Region: opt.Region, Tag: tag, Image: getHookVars(ctx)["IMAGE"]}
+	for _, t := range service.GetTrafficStatuses() {
+		if t.GetTag() == tag {
+			row.URL = t.GetUri()
+			row.Revision = path.Base(t.GetRevision())
+		}
+	}
+	if row.Revision == "" {
+		return PreviewRow{}, fmt.Errorf("tag %s not found", tag)
+	}
+
+	split := TrafficSplit(service)
+	row.Traffic = split[row.Revision]
+	if row.Revision == path.Base(service.GetLatestReadyRevision()) {
+		row.Traffic += split[SWITCH_REVISION_DEFAULT_REVISION]
+	}
+
+	if row.Image == "" {
+		revision, err := gc.GetRevision(ctx, row.Revision)
+		if err != nil {
+			return PreviewRow{}, fmt.Errorf("failed to get revision: %w", err)
+		}
+		if containers := revision.GetContainers(); len(containers) > 0 {
+			row.Image = containers[0].GetImage()
+		}
+	}
+
+	row.Commit = os.Getenv(ENV_GITHUB_SHA)
+	if len(row.Commit) > COMMIT_HASH_LENGTH {
+		row.Commit = row.Commit[:COMMIT_HASH_LENGTH]
+	}
+
+	return row, nil
+}
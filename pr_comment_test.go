@@ -0,0 +1,182 @@
+package dekopin_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commentStandIn serves the comments of one pull request from memory.
+type commentStandIn struct {
+	mu       sync.Mutex
+	comments []dekopin.GitHubComment
+	writes   []string
+}
+
+func (c *commentStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var body struct {
+		Body string `json:"body"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/owner/app/issues/12/comments":
+		_ = json.NewEncoder(w).Encode(c.comments)
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/app/issues/12/comments":
+		c.comments = append(c.comments, dekopin.GitHubComment{ID: int64(100 + len(c.comments)), Body: body.Body})
+		c.writes = append(c.writes, "create")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/repos/owner/app/issues/comments/"):
+		for i := range c.comments {
+			if r.URL.Path == fmt.Sprintf("/repos/owner/app/issues/comments/%d", c.comments[i].ID) {
+				c.comments[i].Body = body.Body
+				c.writes = append(c.writes, fmt.Sprintf("update %d", c.comments[i].ID))
+			}
+		}
+		fmt.Fprint(w, `{}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found"}`)
+	}
+}
+
+func TestUpdatePreviewComment(t *testing.T) {
+	type TestResult struct {
+		Err     error
+		StandIn *commentStandIn
+	}
+
+	type ArrangeResult struct {
+		existing []dekopin.GitHubComment
+		updates  []func(p *dekopin.PreviewComment) bool
+	}
+
+	row := dekopin.PreviewRow{
+		Service:  "app",
+		Region:   "asia-northeast1",
+		Tag:      "pr-12",
+		URL:      "https://pr-12---app-xyz.a.run.app",
+		Revision: "app-abc1234",
+		Image:    "gcr.io/project/app@sha256:0123",
+		Commit:   "abc1234",
+	}
+	upsert := func(r dekopin.PreviewRow) func(p *dekopin.PreviewComment) bool {
+		return func(p *dekopin.PreviewComment) bool {
+			p.Upsert(r)
+			return true
+		}
+	}
+	remove := func(p *dekopin.PreviewComment) bool {
+		return p.MarkRemoved("app", "asia-northeast1", "pr-12")
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_creates_then_updates_one_comment": {
+			Arrange: func() ArrangeResult {
+				next := row
+				next.Revision, next.Commit = "app-def5678", "def5678"
+				return ArrangeResult{
+					existing: []dekopin.GitHubComment{{ID: 1, Body: "LGTM"}},
+					updates:  []func(p *dekopin.PreviewComment) bool{upsert(row), upsert(next)},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"create", "update 101"}, result.StandIn.writes)
+				require.Len(t, result.StandIn.comments, 2)
+				body := result.StandIn.comments[1].Body
+				assert.Contains(t, body, "| app | asia-northeast1 | `pr-12` | https://pr-12---app-xyz.a.run.app | `app-def5678` | 0% | `gcr.io/project/app@sha256:0123` | def5678 |")
+				assert.NotContains(t, body, "app-abc1234 |")
+			},
+		},
+		"success_remove_edits_the_row": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{updates: []func(p *dekopin.PreviewComment) bool{upsert(row), remove}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				require.Len(t, result.StandIn.comments, 1)
+				assert.Contains(t, result.StandIn.comments[0].Body, "| app | asia-northeast1 | `pr-12` | removed | `app-abc1234` | - |")
+			},
+		},
+		"success_remove_without_a_comment_writes_nothing": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{updates: []func(p *dekopin.PreviewComment) bool{remove}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Empty(t, result.StandIn.writes)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			standIn := &commentStandIn{comments: args.existing}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			client := &dekopin.GitHubClient{BaseURL: server.URL, Token: "test-token", Repository: "owner/app", HTTPClient: server.Client()}
+			var err error
+			for _, update := range args.updates {
+				if err = dekopin.UpdatePreviewComment(context.Background(), client, 12, update); err != nil {
+					break
+				}
+			}
+			tc.Assert(t, args, TestResult{Err: err, StandIn: standIn})
+		})
+	}
+}
+
+func TestPullRequestNumber(t *testing.T) {
+	type TestResult struct {
+		Number int
+		OK     bool
+	}
+
+	type ArrangeResult struct {
+		ref string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_pull_request": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{ref: "refs/pull/42/merge"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.True(t, result.OK)
+				assert.Equal(t, 42, result.Number)
+			},
+		},
+		"success_branch_is_not_a_pull_request": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{ref: "refs/heads/main"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.False(t, result.OK)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			number, ok := dekopin.PullRequestNumber(args.ref)
+			tc.Assert(t, args, TestResult{Number: number, OK: ok})
+		})
+	}
+}
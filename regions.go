@@ -0,0 +1,133 @@
+package dekopin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	REVISION_SUFFIX_TIME_FORMAT = "20060102-150405"
+)
+
+// lifecycle wraps every call of a command in a service and region, outermost first, so the
+// history records the result including the hooks.
+var lifecycle = []func(fn func(ctx context.Context) error) func(ctx context.Context) error{
+	withHistory,
+	withNotifications,
+	withGitHubDeployment,
+	withPullRequestComment,
+	withHooks,
+}
+
+type multiRegionKey struct{}
+
+type regionTimeoutKey struct{}
+
+// WithRegionTimeout returns a context whose commands get d in every service and region instead of TIMEOUT.
+func WithRegionTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, regionTimeoutKey{}, d)
+}
+
+func regionTimeout(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(regionTimeoutKey{}).(time.Duration); ok {
+		return d
+	}
+	return TIMEOUT
+}
+
+// IsMultiRegion reports whether the command runs in one of several regions.
+func IsMultiRegion(ctx context.Context) bool {
+	multiRegion, _ := ctx.Value(multiRegionKey{}).(bool)
+	return multiRegion
+}
+
+// RunForEachRegion calls fn once per region when the service is deployed to several regions.
+// Regions are processed in order and the remaining regions are skipped as soon as one fails.
+// With waves the first region is processed alone and the rest concurrently once it succeeded.
+// Without multiple regions fn is called once with ctx as is. The lifecycle of the command runs around every call,
+// and every call gets its own deadline, so a region does not use up the time of the regions after it.
+func RunForEachRegion(ctx context.Context, waves bool, fn func(ctx context.Context) error) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	for _, wrap := range slices.Backward(lifecycle) {
+		fn = wrap(fn)
+	}
+	fn = withRegionTimeout(fn)
+	if len(opt.Regions) == 0 {
+		return fn(ctx)
+	}
+
+	regions := opt.Regions
+	if waves {
+		if err := runInRegion(ctx, opt, regions[0], fn); err != nil {
+			return fmt.Errorf("first wave failed, skipped regions %v: %w", regions[1:], err)
+		}
+		return runInRegionsConcurrently(ctx, opt, regions[1:], fn)
+	}
+
+	for i, region := range regions {
+		if err := runInRegion(ctx, opt, region, fn); err != nil {
+			return fmt.Errorf("aborted, skipped regions %v: %w", regions[i+1:], err)
+		}
+	}
+
+	return nil
+}
+
+func runInRegion(ctx context.Context, opt *CmdOption, region string, fn func(ctx context.Context) error) error {
+	fmt.Fprintf(Stdout(ctx), "Region: %s\n", region)
+	ctx = context.WithValue(ctx, multiRegionKey{}, true)
+	if err := fn(regionContext(ctx, opt, region)); err != nil {
+		return fmt.Errorf("region %s: %w", region, err)
+	}
+
+	return nil
+}
+
+func withRegionTimeout(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, regionTimeout(ctx))
+		defer cancel()
+		return fn(ctx)
+	}
+}
+
+// regionContext returns a context whose CmdOption targets the single region.
+func regionContext(ctx context.Context, opt *CmdOption, region string) context.Context {
+	regionOpt := *opt
+	regionOpt.Region = region
+	regionOpt.Regions = nil
+	return SetCmdOption(ctx, &regionOpt)
+}
+
+func runInRegionsConcurrently(ctx context.Context, opt *CmdOption, regions []string, fn func(ctx context.Context) error) error {
+	errs := make([]error, len(regions))
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every line is prefixed with its region, as the regions print at the same time.
+			stdout := newPrefixWriter(Stdout(ctx), "["+region+"] ")
+			stderr := newPrefixWriter(Stderr(ctx), "["+region+"] ")
+			defer stdout.Flush()
+			defer stderr.Flush()
+			errs[i] = runInRegion(WithOutput(ctx, stdout, stderr), opt, region, fn)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// NewRevisionSuffix returns a revision suffix shared by every region when there is no commit hash to use.
+func NewRevisionSuffix(now time.Time) string {
+	return now.UTC().Format(REVISION_SUFFIX_TIME_FORMAT)
+}
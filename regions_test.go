@@ -0,0 +1,315 @@
+package dekopin_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunForEachRegion(t *testing.T) {
+	type TestResult struct {
+		Called []string
+		Err    error
+	}
+
+	type ArrangeResult struct {
+		ctx     context.Context
+		waves   bool
+		failFor string
+	}
+
+	makeCtx := func(regions ...string) context.Context {
+		opt := &dekopin.CmdOption{
+			Project: "test-project",
+			Region:  "test-region",
+			Service: "test-service",
+			Runner:  dekopin.RUNNER_LOCAL,
+			Regions: regions,
+		}
+		return dekopin.SetCmdOption(context.Background(), opt)
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_without_regions_runs_once_for_the_single_region": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ctx: makeCtx(),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"test-region"}, result.Called)
+			},
+		},
+		"success_runs_every_region_in_order": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ctx: makeCtx("asia-northeast1", "us-central1", "europe-west1"),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"asia-northeast1", "us-central1", "europe-west1"}, result.Called)
+			},
+		},
+		"success_waves_runs_the_first_region_before_the_rest": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ctx:   makeCtx("asia-northeast1", "us-central1", "europe-west1"),
+					waves: true,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, "asia-northeast1", result.Called[0])
+				assert.ElementsMatch(t, []string{"us-central1", "europe-west1"}, result.Called[1:])
+			},
+		},
+		"error_aborts_the_remaining_regions_if_one_fails": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ctx:     makeCtx("asia-northeast1", "us-central1", "europe-west1"),
+					failFor: "us-central1",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Error(t, result.Err)
+				assert.Contains(t, result.Err.Error(), "europe-west1")
+				assert.Equal(t, []string{"asia-northeast1", "us-central1"}, result.Called)
+			},
+		},
+		"error_waves_skips_the_rest_if_the_first_region_fails": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ctx:     makeCtx("asia-northeast1", "us-central1", "europe-west1"),
+					waves:   true,
+					failFor: "asia-northeast1",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Error(t, result.Err)
+				assert.Equal(t, []string{"asia-northeast1"}, result.Called)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+
+			var mu sync.Mutex
+			called := []string{}
+			err := dekopin.RunForEachRegion(ar.ctx, ar.waves, func(ctx context.Context) error {
+				opt, err := dekopin.GetCmdOption(ctx)
+				if err != nil {
+					return err
+				}
+
+				mu.Lock()
+				called = append(called, opt.Region)
+				mu.Unlock()
+
+				if opt.Region == ar.failFor {
+					return fmt.Errorf("deploy failed")
+				}
+				return nil
+			})
+
+			c.Assert(t, ar, TestResult{
+				Called: called,
+				Err:    err,
+			})
+		})
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func TestRunForEachRegionOutput(t *testing.T) {
+	type TestResult struct {
+		Lines []string
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		regions []string
+		waves   bool
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_waves_prefix_the_lines_of_the_concurrent_regions": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					regions: []string{"asia-northeast1", "us-central1", "europe-west1"},
+					waves:   true,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"Region: asia-northeast1", "deploying asia-northeast1", "done"}, result.Lines[:3])
+				assert.ElementsMatch(t, []string{
+					"[us-central1] Region: us-central1",
+					"[us-central1] deploying us-central1",
+					"[us-central1] done",
+					"[europe-west1] Region: europe-west1",
+					"[europe-west1] deploying europe-west1",
+					"[europe-west1] done",
+				}, result.Lines[3:])
+			},
+		},
+		"success_sequential_regions_are_not_prefixed": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					regions: []string{"asia-northeast1", "us-central1"},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{
+					"Region: asia-northeast1", "deploying asia-northeast1", "done",
+					"Region: us-central1", "deploying us-central1", "done",
+				}, result.Lines)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+
+			out := &syncBuffer{}
+			ctx := dekopin.SetCmdOption(context.Background(), &dekopin.CmdOption{
+				Project: "test-project",
+				Service: "test-service",
+				Runner:  dekopin.RUNNER_LOCAL,
+				Regions: ar.regions,
+			})
+			ctx = dekopin.WithOutput(ctx, out, out)
+
+			err := dekopin.RunForEachRegion(ctx, ar.waves, func(ctx context.Context) error {
+				opt, err := dekopin.GetCmdOption(ctx)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(dekopin.Stdout(ctx), "deploying %s\n", opt.Region)
+				fmt.Fprintln(dekopin.Stdout(ctx), "done")
+				return nil
+			})
+
+			c.Assert(t, ar, TestResult{
+				Lines: strings.Split(strings.TrimSuffix(out.buf.String(), "\n"), "\n"),
+				Err:   err,
+			})
+		})
+	}
+}
+
+func TestRunForEachRegionTimeout(t *testing.T) {
+	type TestResult struct {
+		Expired []string
+		Err     error
+	}
+
+	type ArrangeResult struct {
+		regions []string
+		work    time.Duration
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_every_region_gets_its_own_deadline": {
+			Arrange: func() ArrangeResult {
+				// The regions together take longer than the timeout, each one alone does not.
+				return ArrangeResult{
+					regions: []string{"asia-northeast1", "us-central1", "europe-west1"},
+					work:    60 * time.Millisecond,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Empty(t, result.Expired)
+			},
+		},
+		"success_without_regions_the_single_region_gets_a_deadline": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					work: 60 * time.Millisecond,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Empty(t, result.Expired)
+			},
+		},
+		"error_a_region_outlasting_its_deadline_fails": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					regions: []string{"asia-northeast1", "us-central1"},
+					work:    time.Second,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorIs(t, result.Err, context.DeadlineExceeded)
+				assert.Equal(t, []string{"asia-northeast1"}, result.Expired)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+
+			ctx := dekopin.SetCmdOption(context.Background(), &dekopin.CmdOption{
+				Project: "test-project",
+				Region:  "test-region",
+				Service: "test-service",
+				Runner:  dekopin.RUNNER_LOCAL,
+				Regions: ar.regions,
+			})
+			ctx = dekopin.WithOutput(ctx, io.Discard, io.Discard)
+			ctx = dekopin.WithRegionTimeout(ctx, 100*time.Millisecond)
+
+			expired := []string{}
+			err := dekopin.RunForEachRegion(ctx, false, func(ctx context.Context) error {
+				opt, err := dekopin.GetCmdOption(ctx)
+				if err != nil {
+					return err
+				}
+
+				if _, ok := ctx.Deadline(); !ok {
+					return fmt.Errorf("region %s has no deadline", opt.Region)
+				}
+
+				select {
+				case <-time.After(ar.work):
+					return nil
+				case <-ctx.Done():
+					expired = append(expired, opt.Region)
+					return ctx.Err()
+				}
+			})
+
+			c.Assert(t, ar, TestResult{
+				Expired: expired,
+				Err:     err,
+			})
+		})
+	}
+}
@@ -3,6 +3,7 @@ package dekopin
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -57,7 +58,26 @@ func removeTagCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get tag name: %w", err)
 	}
 
-	return removeTag(ctx, gc, tag)
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	force, err := dekopinCmd.GetForceByFlag()
+	if err != nil {
+		return err
+	}
+
+	if IsProtectedTag(opt.ProtectedTags, tag) && !force {
+		return fmt.Errorf("tag %s is protected. Pass --force to remove it", tag)
+	}
+
+	ctx = WithHookVars(ctx, map[string]string{"TAG": tag})
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return removeTag(ctx, gc, tag)
+		})
+	})
 }
 
 func removeTag(ctx context.Context, gc GCloud, tag string) error {
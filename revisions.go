@@ -0,0 +1,87 @@
+package dekopin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var revisionsCmd = &cobra.Command{
+	Use:   "revisions",
+	Short: "Inspect Cloud Run revisions",
+}
+
+var revisionsDescribeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Show the image, environment and secret references of a revision",
+	RunE:  revisionsDescribeCommand,
+}
+
+func revisionsDescribeCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	revision, err := dekopinCmd.GetRevisionByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get revision flag: %w", err)
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return describeRevision(ctx, gc, os.Stdout, revision)
+		})
+	})
+}
+
+func describeRevision(ctx context.Context, gc GCloud, w io.Writer, revisionName string) error {
+	revision, err := gc.GetRevision(ctx, revisionName)
+	if err != nil {
+		return fmt.Errorf("failed to get revision: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get service: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Revision:        %s\n", path.Base(revision.GetName()))
+	fmt.Fprintf(&buf, "Created:         %s\n", revision.GetCreateTime().AsTime().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "Service account: %s\n", revision.GetServiceAccount())
+	for _, c := range revision.GetContainers() {
+		fmt.Fprintf(&buf, "Image:           %s\n", c.GetImage())
+		if source, ok := service.GetAnnotations()[ANNOTATION_SOURCE_IMAGE_PREFIX+revisionName]; ok {
+			fmt.Fprintf(&buf, "Source image:    %s\n", source)
+		}
+		for _, env := range c.GetEnv() {
+			// Secret backed variables are listed under Secrets without their value.
+			if env.GetValueSource() == nil {
+				fmt.Fprintf(&buf, "Env:             %s=%s\n", env.GetName(), env.GetValue())
+			}
+		}
+	}
+
+	if err := writeSecretRefs(&buf, RevisionSecretRefs(revision.GetContainers(), revision.GetVolumes())); err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package dekopin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+const (
+	SECRET_VERSION_LATEST = "latest"
+)
+
+// DefaultPinnedSecretEnvironments are the environments where secret versions must be pinned
+// when the configuration file does not list them.
+var DefaultPinnedSecretEnvironments = []string{"production"}
+
+// SecretPolicy controls which secret versions may be referenced by a revision.
+type SecretPolicy struct {
+	PinnedEnvironments []string `yaml:"pinned_environments,omitempty"`
+}
+
+var secretRefPattern = regexp.MustCompile(`^(projects/[^/]+/secrets/)?[A-Za-z0-9_-]+(:[A-Za-z0-9_-]+)?$`)
+
+// ParseSecretFlags parses --secret values of the form ENV_NAME=secret:version or /mount/path=secret:version.
+func ParseSecretFlags(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	secrets := map[string]string{}
+	for _, v := range values {
+		target, ref, ok := strings.Cut(v, "=")
+		if !ok || target == "" || ref == "" {
+			return nil, fmt.Errorf("invalid secret %q. Expected ENV_NAME=secret:version or /mount/path=secret:version", v)
+		}
+		secrets[target] = ref
+	}
+
+	return secrets, nil
+}
+
+// splitSecretRef splits secret:version. A reference without a version uses the latest version.
+func splitSecretRef(ref string) (string, string) {
+	secret, version, ok := strings.Cut(ref, ":")
+	if !ok {
+		return secret, SECRET_VERSION_LATEST
+	}
+	return secret, version
+}
+
+func validateSecrets(secrets map[string]string) error {
+	errs := []error{}
+	for _, target := range slices.Sorted(maps.Keys(secrets)) {
+		if !strings.HasPrefix(target, "/") && !envNamePattern.MatchString(target) {
+			errs = append(errs, fmt.Errorf("spec.secrets: %q must be an environment variable name or an absolute mount path", target))
+		}
+		if !secretRefPattern.MatchString(secrets[target]) {
+			errs = append(errs, fmt.Errorf("spec.secrets: invalid secret reference %q for %s. Expected secret:version", secrets[target], target))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Check rejects secrets that are not pinned to a version in the environments that require it.
+func (p *SecretPolicy) Check(secrets map[string]string, environment string) error {
+	pinned := DefaultPinnedSecretEnvironments
+	if p != nil && p.PinnedEnvironments != nil {
+		pinned = p.PinnedEnvironments
+	}
+
+	if environment == "" || !slices.Contains(pinned, environment) {
+		return nil
+	}
+
+	errs := []error{}
+	for _, target := range slices.Sorted(maps.Keys(secrets)) {
+		if _, version := splitSecretRef(secrets[target]); version == SECRET_VERSION_LATEST {
+			errs = append(errs, fmt.Errorf("secret %s for %s must be pinned to a version in the %s environment", secrets[target], target, environment))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// withRevisionSpec applies the --secret flags on top of the spec of the current service and
+// checks the spec and the secret policy before anything is deployed.
+func withRevisionSpec(ctx context.Context, secretFlags map[string]string) (context.Context, error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	specOpt := *opt
+	if len(secretFlags) > 0 {
+		specOpt.Spec = MergeServiceSpec(opt.Spec, &ServiceSpec{Secrets: secretFlags})
+	}
+
+	if err := specOpt.Spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+
+	if specOpt.Spec != nil {
+		if err := specOpt.SecretPolicy.Check(specOpt.Spec.Secrets, specOpt.Environment); err != nil {
+			return nil, err
+		}
+	}
+
+	return SetCmdOption(ctx, &specOpt), nil
+}
+
+// SecretRef is a secret referenced by a revision. It never holds the secret value.
+type SecretRef struct {
+	Target  string
+	Secret  string
+	Version string
+}
+
+// RevisionSecretRefs returns the secrets exposed as environment variables or mounted as volumes.
+func RevisionSecretRefs(containers []*runpb.Container, volumes []*runpb.Volume) []SecretRef {
+	refs := []SecretRef{}
+	mountPaths := map[string]string{}
+	for _, c := range containers {
+		for _, env := range c.GetEnv() {
+			if ref := env.GetValueSource().GetSecretKeyRef(); ref != nil {
+				refs = append(refs, SecretRef{Target: env.GetName(), Secret: ref.GetSecret(), Version: ref.GetVersion()})
+			}
+		}
+		for _, m := range c.GetVolumeMounts() {
+			mountPaths[m.GetName()] = m.GetMountPath()
+		}
+	}
+
+	for _, v := range volumes {
+		secret := v.GetSecret()
+		if secret == nil {
+			continue
+		}
+
+		target := mountPaths[v.GetName()]
+		if len(secret.GetItems()) == 0 {
+			refs = append(refs, SecretRef{Target: target, Secret: secret.GetSecret(), Version: SECRET_VERSION_LATEST})
+		}
+		for _, item := range secret.GetItems() {
+			refs = append(refs, SecretRef{Target: target + "/" + item.GetPath(), Secret: secret.GetSecret(), Version: item.GetVersion()})
+		}
+	}
+
+	return refs
+}
+
+func writeSecretRefs(w io.Writer, refs []SecretRef) error {
+	if len(refs) == 0 {
+		_, err := fmt.Fprintln(w, "Secrets:         (none)")
+		return err
+	}
+
+	fmt.Fprintln(w, "Secrets:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TARGET\tSECRET\tVERSION")
+	for _, r := range refs {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", r.Target, r.Secret, r.Version)
+	}
+	return tw.Flush()
+}
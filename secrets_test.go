@@ -0,0 +1,180 @@
+package dekopin_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretPolicy_Check(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		policy      *dekopin.SecretPolicy
+		secrets     map[string]string
+		environment string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_pinned_versions_in_production": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					secrets:     map[string]string{"DB_PASSWORD": "db-password:3", "/secrets/key": "api-key:1"},
+					environment: "production",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_latest_outside_pinned_environments": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					secrets:     map[string]string{"DB_PASSWORD": "db-password:latest"},
+					environment: "staging",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_latest_in_production_by_default": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					secrets:     map[string]string{"DB_PASSWORD": "db-password:latest"},
+					environment: "production",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "must be pinned")
+			},
+		},
+		"error_unversioned_secret_in_configured_environment": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					policy:      &dekopin.SecretPolicy{PinnedEnvironments: []string{"prd"}},
+					secrets:     map[string]string{"DB_PASSWORD": "db-password"},
+					environment: "prd",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "must be pinned")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			err := ar.policy.Check(ar.secrets, ar.environment)
+			c.Assert(t, ar, TestResult{
+				Err: err,
+			})
+		})
+	}
+}
+
+func TestParseSecretFlags(t *testing.T) {
+	type TestResult struct {
+		Secrets map[string]string
+		Err     error
+	}
+
+	type ArrangeResult struct {
+		values []string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_env_and_volume_secrets": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					values: []string{"DB_PASSWORD=db-password:3", "/secrets/key=api-key:1"},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, map[string]string{
+					"DB_PASSWORD":  "db-password:3",
+					"/secrets/key": "api-key:1",
+				}, result.Secrets)
+			},
+		},
+		"error_missing_secret_reference": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					values: []string{"DB_PASSWORD"},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Error(t, result.Err)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			secrets, err := dekopin.ParseSecretFlags(ar.values)
+			c.Assert(t, ar, TestResult{
+				Secrets: secrets,
+				Err:     err,
+			})
+		})
+	}
+}
+
+func TestRevisionSecretRefs(t *testing.T) {
+	type TestResult struct {
+		Refs []dekopin.SecretRef
+	}
+
+	type ArrangeResult struct {
+		containers []*runpb.Container
+		volumes    []*runpb.Volume
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_lists_env_and_volume_secrets_without_plain_env": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					containers: []*runpb.Container{
+						{
+							Env: []*runpb.EnvVar{
+								{Name: "APP_ENV", Values: &runpb.EnvVar_Value{Value: "production"}},
+								{Name: "DB_PASSWORD", Values: &runpb.EnvVar_ValueSource{ValueSource: &runpb.EnvVarSource{
+									SecretKeyRef: &runpb.SecretKeySelector{Secret: "db-password", Version: "3"},
+								}}},
+							},
+							VolumeMounts: []*runpb.VolumeMount{{Name: "api-key", MountPath: "/secrets"}},
+						},
+					},
+					volumes: []*runpb.Volume{
+						{Name: "api-key", VolumeType: &runpb.Volume_Secret{Secret: &runpb.SecretVolumeSource{
+							Secret: "api-key",
+							Items:  []*runpb.VersionToPath{{Path: "key", Version: "1"}},
+						}}},
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, []dekopin.SecretRef{
+					{Target: "DB_PASSWORD", Secret: "db-password", Version: "3"},
+					{Target: "/secrets/key", Secret: "api-key", Version: "1"},
+				}, result.Refs)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Refs: dekopin.RevisionSecretRefs(ar.containers, ar.volumes),
+			})
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package dekopin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	DEFAULT_CONCURRENCY = 3
+)
+
+type serviceConfigKey struct{}
+
+func SetServiceConfig(ctx context.Context, service *ServiceConfig) context.Context {
+	return context.WithValue(ctx, serviceConfigKey{}, service)
+}
+
+// GetServiceConfig returns the service the command is currently running for.
+// It is only present when dekopin.yml declares several services.
+func GetServiceConfig(ctx context.Context) (*ServiceConfig, bool) {
+	service, ok := ctx.Value(serviceConfigKey{}).(*ServiceConfig)
+	return service, ok
+}
+
+type ServiceResult struct {
+	Service  string
+	Region   string
+	Duration time.Duration
+	Err      error
+}
+
+// RunForEachService calls fn once per selected service, concurrently and bounded by the
+// configured concurrency. Each call receives a context whose CmdOption targets that service.
+// Without a services list fn is called once with ctx as is.
+func RunForEachService(ctx context.Context, w io.Writer, fn func(ctx context.Context) error) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if len(opt.Services) == 0 {
+		return fn(ctx)
+	}
+
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_CONCURRENCY
+	}
+
+	results := make([]ServiceResult, len(opt.Services))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, service := range opt.Services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			serviceCtx, serviceOpt := serviceContext(ctx, opt, service)
+
+			start := time.Now()
+			err := fn(serviceCtx)
+			results[i] = ServiceResult{
+				Service:  service.Name,
+				Region:   strings.Join(serviceOpt.TargetRegions(), ","),
+				Duration: time.Since(start),
+				Err:      err,
+			}
+		}()
+	}
+	wg.Wait()
+
+	return reportServiceResults(w, results)
+}
+
+// serviceContext returns a context whose CmdOption targets the service.
+func serviceContext(ctx context.Context, opt *CmdOption, service ServiceConfig) (context.Context, *CmdOption) {
+	serviceOpt := *opt
+	serviceOpt.Service = service.Name
+	serviceOpt.Services = nil
+	serviceOpt.Spec = MergeServiceSpec(opt.Spec, service.Spec)
+	if service.Region != "" {
+		serviceOpt.Region = service.Region
+		serviceOpt.Regions = nil
+	}
+
+	serviceCtx := SetCmdOption(ctx, &serviceOpt)
+	return SetServiceConfig(serviceCtx, &service), &serviceOpt
+}
+
+func reportServiceResults(w io.Writer, results []ServiceResult) error {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tREGION\tRESULT\tDURATION\tERROR")
+	for _, r := range results {
+		result := "OK"
+		errMsg := ""
+		if r.Err != nil {
+			failed++
+			result = "FAILED"
+			errMsg = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Service, r.Region, result, r.Duration.Round(time.Second), errMsg)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write service results: %w", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d services failed", failed, len(results))
+	}
+
+	return nil
+}
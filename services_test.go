@@ -0,0 +1,184 @@
+package dekopin_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunForEachService(t *testing.T) {
+	type TestResult struct {
+		Called []string
+		Output string
+		Err    error
+	}
+
+	type ArrangeResult struct {
+		ctx     context.Context
+		failFor string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_without_services_runs_once_for_the_single_service": {
+			Arrange: func() ArrangeResult {
+				opt := &dekopin.CmdOption{
+					Project: "test-project",
+					Region:  "test-region",
+					Service: "test-service",
+					Runner:  dekopin.RUNNER_LOCAL,
+				}
+				return ArrangeResult{
+					ctx: dekopin.SetCmdOption(context.Background(), opt),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"test-service/test-region"}, result.Called)
+				assert.Empty(t, result.Output)
+			},
+		},
+		"success_with_services_runs_for_each_service": {
+			Arrange: func() ArrangeResult {
+				opt := &dekopin.CmdOption{
+					Project: "test-project",
+					Runner:  dekopin.RUNNER_LOCAL,
+					Services: []dekopin.ServiceConfig{
+						{Name: "api", Region: "asia-northeast1"},
+						{Name: "web", Region: "us-central1"},
+						{Name: "worker", Region: "asia-northeast1"},
+					},
+					Concurrency: 2,
+				}
+				return ArrangeResult{
+					ctx: dekopin.SetCmdOption(context.Background(), opt),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.ElementsMatch(t, []string{
+					"api/asia-northeast1",
+					"web/us-central1",
+					"worker/asia-northeast1",
+				}, result.Called)
+				assert.Contains(t, result.Output, "SERVICE")
+			},
+		},
+		"error_if_one_of_the_services_fails": {
+			Arrange: func() ArrangeResult {
+				opt := &dekopin.CmdOption{
+					Project: "test-project",
+					Runner:  dekopin.RUNNER_LOCAL,
+					Services: []dekopin.ServiceConfig{
+						{Name: "api", Region: "asia-northeast1"},
+						{Name: "web", Region: "us-central1"},
+					},
+				}
+				return ArrangeResult{
+					ctx:     dekopin.SetCmdOption(context.Background(), opt),
+					failFor: "web",
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.EqualError(t, result.Err, "1 of 2 services failed")
+				assert.Len(t, result.Called, 2)
+				assert.Contains(t, result.Output, "FAILED")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+
+			var mu sync.Mutex
+			called := []string{}
+			var out bytes.Buffer
+			err := dekopin.RunForEachService(ar.ctx, &out, func(ctx context.Context) error {
+				opt, err := dekopin.GetCmdOption(ctx)
+				if err != nil {
+					return err
+				}
+
+				mu.Lock()
+				called = append(called, opt.Service+"/"+opt.Region)
+				mu.Unlock()
+
+				if opt.Service == ar.failFor {
+					return fmt.Errorf("deploy failed")
+				}
+				return nil
+			})
+
+			c.Assert(t, ar, TestResult{
+				Called: called,
+				Output: out.String(),
+				Err:    err,
+			})
+		})
+	}
+}
+
+func TestExecuteInServicesMode(t *testing.T) {
+	type TestResult struct {
+		Calls []string
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		args []string
+	}
+
+	config := filepath.Join(t.TempDir(), "dekopin.yml")
+	if err := os.WriteFile(config, []byte("project: test-project\nregion: asia-northeast1\nrunner: local\nservices:\n  - name: api\n  - name: web\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_revisions_describe_runs_for_every_selected_service": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					args: []string{"revisions", "describe", "--file", config, "--service", "api,web", "--revision", "rev-001"},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.ElementsMatch(t, []string{
+					"GetRevision api/asia-northeast1", "GetService api/asia-northeast1",
+					"GetRevision web/asia-northeast1", "GetService web/asia-northeast1",
+				}, result.Calls)
+			},
+		},
+		"success_revisions_describe_runs_for_the_service_flag": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					args: []string{"revisions", "describe", "--file", config, "--service", "web", "--revision", "rev-001"},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.ElementsMatch(t, []string{"GetRevision web/asia-northeast1", "GetService web/asia-northeast1"}, result.Calls)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+
+			gc := &fakeGCloud{}
+			err := dekopin.Execute(dekopin.SetGCloud(context.Background(), gc), ar.args)
+
+			c.Assert(t, ar, TestResult{
+				Calls: gc.calls,
+				Err:   err,
+			})
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package dekopin
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	COSIGN_SIGNATURE_ANNOTATION = "dev.cosignproject.cosign/signature"
+	COSIGN_SIMPLE_SIGNING_TYPE  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	COSIGN_SIGNATURE_SUFFIX     = ".sig"
+)
+
+// SignaturePolicy requires images to carry a cosign signature made with one of the public keys.
+// Verification uses the keys alone; no transparency log or certificate authority is consulted.
+type SignaturePolicy struct {
+	PublicKeys []string `yaml:"public_keys,omitempty"`
+}
+
+func (p *SignaturePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+
+	errs := []error{}
+	for i, key := range p.PublicKeys {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, fmt.Errorf("signature_policy.public_keys[%d]: path must not be empty", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Enabled reports whether images must be verified.
+func (p *SignaturePolicy) Enabled() bool {
+	return p != nil && len(p.PublicKeys) > 0
+}
+
+// LoadPublicKeys reads PEM encoded public keys, as written by cosign generate-key-pair.
+func LoadPublicKeys(paths []string) ([]crypto.PublicKey, error) {
+	keys := []crypto.PublicKey{}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %w", err)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s is not a PEM encoded public key", p)
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", p, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// signaturePayload is the part of the cosign simple signing payload that binds it to an image.
+type signaturePayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+type signatureManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// VerifySignature checks that the signature image cosign stores next to ref contains a
+// signature for ref's digest made with one of keys.
+func (c *RegistryClient) VerifySignature(ctx context.Context, ref ImageRef, keys []crypto.PublicKey) error {
+	if ref.Digest == "" {
+		return fmt.Errorf("image %s must be resolved to a digest before it is verified", ref)
+	}
+
+	sigTag := strings.Replace(ref.Digest, ":", "-", 1) + COSIGN_SIGNATURE_SUFFIX
+	resp, body, err := c.get(ctx, http.MethodGet, ref, "manifests/"+sigTag, manifestMediaTypes)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("no signature found for %s", ref)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get signature of %s: registry returned %s", ref, resp.Status)
+	}
+
+	var manifest signatureManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return fmt.Errorf("failed to decode signature manifest of %s: %w", ref, err)
+	}
+
+	errs := []error{}
+	for _, layer := range manifest.Layers {
+		signature, ok := layer.Annotations[COSIGN_SIGNATURE_ANNOTATION]
+		if layer.MediaType != COSIGN_SIMPLE_SIGNING_TYPE || !ok {
+			continue
+		}
+
+		payload, err := c.getBlob(ctx, ref, layer.Digest)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := verifyPayload(ref.Digest, payload, signature, keys); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return nil
+	}
+
+	if len(errs) == 0 {
+		return fmt.Errorf("no signature found for %s", ref)
+	}
+	return fmt.Errorf("no valid signature for %s: %w", ref, errors.Join(errs...))
+}
+
+// getBlob downloads a blob and checks it against its digest.
+func (c *RegistryClient) getBlob(ctx context.Context, ref ImageRef, digest string) ([]byte, error) {
+	resp, body, err := c.get(ctx, http.MethodGet, ref, "blobs/"+digest, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get blob %s: registry returned %s", digest, resp.Status)
+	}
+
+	sum := sha256.Sum256(body)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("blob %s does not match its digest", digest)
+	}
+
+	return body, nil
+}
+
+// verifyPayload checks that payload names digest and that signature, base64 encoded, is valid for one of keys.
+func verifyPayload(digest string, payload []byte, signature string, keys []crypto.PublicKey) error {
+	var p signaturePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("failed to decode signature payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for %s, not %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	hashed := sha256.Sum256(payload)
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, hashed[:], sig) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, hashed[:], sig) == nil {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, sig) {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("signature does not match any configured public key")
+}
+
+// VerifyImage verifies the signature of a digest reference when the signature policy requires it.
+func VerifyImage(ctx context.Context, image string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if !opt.SignaturePolicy.Enabled() {
+		return nil
+	}
+
+	ref, err := ParseImageRef(image)
+	if err != nil {
+		return err
+	}
+
+	keys, err := LoadPublicKeys(opt.SignaturePolicy.PublicKeys)
+	if err != nil {
+		return err
+	}
+
+	if err := GetRegistryClient(ctx).VerifySignature(ctx, ref, keys); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	fmt.Fprintf(Stdout(ctx), "Verified signature of %s\n", image)
+	return nil
+}
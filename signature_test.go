@@ -0,0 +1,150 @@
+package dekopin_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSignedRegistry serves a cosign signature for testDigest whose payload names signedDigest.
+func newSignedRegistry(t *testing.T, key *ecdsa.PrivateKey, signedDigest string) *httptest.Server {
+	t.Helper()
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"team/app"},"image":{"docker-manifest-digest":"` + signedDigest + `"},"type":"cosign container image signature"},"optional":null}`)
+	hashed := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hashed[:])
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(payload)
+	payloadDigest := "sha256:" + hex.EncodeToString(sum[:])
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"layers": []map[string]any{{
+			"mediaType":   dekopin.COSIGN_SIMPLE_SIGNING_TYPE,
+			"digest":      payloadDigest,
+			"annotations": map[string]string{dekopin.COSIGN_SIGNATURE_ANNOTATION: base64.StdEncoding.EncodeToString(sig)},
+		}},
+	})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/team/app/manifests/" + strings.Replace(testDigest, ":", "-", 1) + ".sig":
+			w.Write(manifest)
+		case "/v2/team/app/blobs/" + payloadDigest:
+			w.Write(payload)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writePublicKey(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	p := filepath.Join(t.TempDir(), "cosign.pub")
+	require.NoError(t, os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return p
+}
+
+func TestVerifyImage(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		ctx   context.Context
+		image string
+	}
+
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	arrange := func(server *httptest.Server, publicKey crypto.PublicKey, image string) ArrangeResult {
+		ctx := dekopin.SetRegistryClient(context.Background(), &dekopin.RegistryClient{HTTPClient: server.Client()})
+		ctx = dekopin.SetCmdOption(ctx, &dekopin.CmdOption{
+			SignaturePolicy: &dekopin.SignaturePolicy{PublicKeys: []string{writePublicKey(t, publicKey)}},
+		})
+		return ArrangeResult{ctx: ctx, image: strings.TrimPrefix(server.URL, "http://") + "/team/app@" + image}
+	}
+
+	signed := newSignedRegistry(t, signer, testDigest)
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_signed_with_configured_key": {
+			Arrange: func() ArrangeResult {
+				return arrange(signed, &signer.PublicKey, testDigest)
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_policy_disabled": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					ctx:   dekopin.SetCmdOption(context.Background(), &dekopin.CmdOption{}),
+					image: "docker.io/random/image@" + testDigest,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_signed_with_other_key": {
+			Arrange: func() ArrangeResult {
+				return arrange(signed, &other.PublicKey, testDigest)
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "does not match any configured public key")
+			},
+		},
+		"error_signature_for_other_digest": {
+			Arrange: func() ArrangeResult {
+				return arrange(newSignedRegistry(t, signer, "sha256:0000"), &signer.PublicKey, testDigest)
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "signature is for sha256:0000")
+			},
+		},
+		"error_unsigned_image": {
+			Arrange: func() ArrangeResult {
+				return arrange(signed, &signer.PublicKey, "sha256:1111")
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "no signature found")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Err: dekopin.VerifyImage(ar.ctx, ar.image),
+			})
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package dekopin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// SMOKE_TAG is the temporary tag used to reach a new revision when deploy does not create a tag.
+	SMOKE_TAG = "dekopin-smoke"
+
+	DEFAULT_SMOKE_STATUS   = http.StatusOK
+	DEFAULT_SMOKE_TIMEOUT  = 10 * time.Second
+	DEFAULT_SMOKE_INTERVAL = 2 * time.Second
+	MAX_SMOKE_BODY_SIZE    = 1 << 20
+)
+
+// SmokeConfig lists the HTTP checks a revision must pass on its tag URL before traffic is moved to it.
+type SmokeConfig struct {
+	Checks             []SmokeCheck `yaml:"checks,omitempty"`
+	RemoveTagOnFailure bool         `yaml:"remove_tag_on_failure,omitempty"`
+}
+
+type SmokeCheck struct {
+	Path     string            `yaml:"path"`
+	Status   int               `yaml:"status,omitempty"`
+	Body     string            `yaml:"body,omitempty"` // Regular expression the response body must match
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Timeout  time.Duration     `yaml:"timeout,omitempty"`
+	Retries  int               `yaml:"retries,omitempty"`
+	Interval time.Duration     `yaml:"interval,omitempty"` // Wait between retries
+}
+
+// Enabled reports whether any check is configured.
+func (c *SmokeConfig) Enabled() bool {
+	return c != nil && len(c.Checks) > 0
+}
+
+func (c *SmokeConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	errs := []error{}
+	for i, check := range c.Checks {
+		if !strings.HasPrefix(check.Path, "/") {
+			errs = append(errs, fmt.Errorf("smoke.checks[%d]: path must start with /", i))
+		}
+		if check.Status != 0 && (check.Status < 100 || check.Status > 599) {
+			errs = append(errs, fmt.Errorf("smoke.checks[%d]: invalid status %d", i, check.Status))
+		}
+		if _, err := regexp.Compile(check.Body); err != nil {
+			errs = append(errs, fmt.Errorf("smoke.checks[%d]: invalid body pattern: %w", i, err))
+		}
+		if check.Timeout < 0 || check.Interval < 0 || check.Retries < 0 {
+			errs = append(errs, fmt.Errorf("smoke.checks[%d]: timeout, interval and retries must not be negative", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RunSmokeChecks runs every check against baseURL. A check is retried until it passes or its retries are used up.
+func RunSmokeChecks(ctx context.Context, client *http.Client, baseURL string, checks []SmokeCheck) error {
+	for _, check := range checks {
+		interval := check.Interval
+		if interval == 0 {
+			interval = DEFAULT_SMOKE_INTERVAL
+		}
+
+		var err error
+		for attempt := 0; attempt <= check.Retries; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(interval):
+				}
+			}
+
+			if err = runSmokeCheck(ctx, client, baseURL, check); err == nil {
+				break
+			}
+			fmt.Fprintf(Stdout(ctx), "Smoke check %s failed (attempt %d/%d): %v\n", check.Path, attempt+1, check.Retries+1, err)
+		}
+
+		if err != nil {
+			return fmt.Errorf("smoke check %s failed: %w", check.Path, err)
+		}
+		fmt.Fprintf(Stdout(ctx), "Smoke check %s passed\n", check.Path)
+	}
+
+	return nil
+}
+
+func runSmokeCheck(ctx context.Context, client *http.Client, baseURL string, check SmokeCheck) error {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_SMOKE_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+check.Path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range check.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	status := check.Status
+	if status == 0 {
+		status = DEFAULT_SMOKE_STATUS
+	}
+	if resp.StatusCode != status {
+		return fmt.Errorf("expected status %d, got %d", status, resp.StatusCode)
+	}
+
+	if check.Body != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, MAX_SMOKE_BODY_SIZE))
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		if !regexp.MustCompile(check.Body).Match(body) {
+			return fmt.Errorf("body does not match %q", check.Body)
+		}
+	}
+
+	return nil
+}
+
+// TagURL returns the URL Cloud Run serves the tagged revision at.
+func TagURL(ctx context.Context, gc GCloud, tag string) (string, error) {
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range service.GetTrafficStatuses() {
+		if t.GetTag() == tag && t.GetUri() != "" {
+			return t.GetUri(), nil
+		}
+	}
+
+	return "", fmt.Errorf("tag %s has no URL", tag)
+}
+
+// smokeCheckTag runs the configured smoke checks against the tag URL. On failure the tag is
+// removed when the configuration asks for it.
+func smokeCheckTag(ctx context.Context, gc GCloud, client *http.Client, tag string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if !opt.Smoke.Enabled() {
+		return nil
+	}
+
+	url, err := TagURL(ctx, gc, tag)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout(ctx), "Running smoke checks against %s\n", url)
+	checkErr := RunSmokeChecks(ctx, client, url, opt.Smoke.Checks)
+	if checkErr == nil {
+		return nil
+	}
+
+	if opt.Smoke.RemoveTagOnFailure {
+		if err := gc.RemoveRevisionTag(ctx, tag); err != nil {
+			return errors.Join(checkErr, fmt.Errorf("failed to remove tag %s: %w", tag, err))
+		}
+	}
+
+	return fmt.Errorf("traffic was not moved: %w", checkErr)
+}
+
+// deployWithSmokeChecks deploys without traffic, checks the new revision on its tag URL and only
+// then moves traffic to it. Without a tag to create, a temporary tag is used for the checks.
+// Traffic follows the latest revision afterwards, like a deploy without smoke checks.
+func deployWithSmokeChecks(ctx context.Context, gc GCloud, client *http.Client, flags *DeployCommandFlags, commitHash string) error {
+	if err := gc.Deploy(ctx, flags.Image, commitHash, false); err != nil {
+		return fmt.Errorf("failed to deploy to Cloud Run: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return err
+	}
+	revision := path.Base(service.GetLatestCreatedRevision())
+
+	tag := SMOKE_TAG
+	if flags.ShouldCreateTag {
+		tag = flags.Tag
+	}
+
+	if err := gc.CreateRevisionTag(ctx, tag, revision); err != nil {
+		return fmt.Errorf("failed to create revision tag: %w", err)
+	}
+
+	if err := smokeCheckTag(ctx, gc, client, tag); err != nil {
+		if tag == SMOKE_TAG {
+			// The temporary tag goes either way; a failure to remove it must not hide the check result.
+			_ = gc.RemoveRevisionTag(ctx, tag)
+		}
+		return err
+	}
+
+	if err := gc.UpdateTrafficToLatestRevision(ctx); err != nil {
+		return fmt.Errorf("failed to update traffic to latest revision: %w", err)
+	}
+
+	if tag == SMOKE_TAG {
+		if err := gc.RemoveRevisionTag(ctx, tag); err != nil {
+			return fmt.Errorf("failed to remove revision tag: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package dekopin_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSmokeChecks(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		checks []dekopin.SmokeCheck
+	}
+
+	// /warming fails twice before it starts serving.
+	var warming atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			if r.Header.Get("X-Smoke") != "1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"status":"ok","version":"v1.2.3"}`))
+		case "/warming":
+			if warming.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_status_body_and_headers": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{checks: []dekopin.SmokeCheck{
+					{Path: "/healthz", Body: `"version":"v1\.`, Headers: map[string]string{"X-Smoke": "1"}},
+					{Path: "/missing", Status: http.StatusNotFound},
+				}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_after_retries": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{checks: []dekopin.SmokeCheck{{Path: "/warming", Retries: 2, Interval: time.Millisecond}}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_body_does_not_match": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{checks: []dekopin.SmokeCheck{{Path: "/healthz", Body: "v2", Headers: map[string]string{"X-Smoke": "1"}}}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "body does not match")
+			},
+		},
+		"error_unexpected_status": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{checks: []dekopin.SmokeCheck{{Path: "/healthz", Retries: 1, Interval: time.Millisecond}}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "expected status 200, got 403")
+			},
+		},
+		"error_timeout": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{checks: []dekopin.SmokeCheck{{Path: "/slow", Timeout: 20 * time.Millisecond}}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorIs(t, result.Err, context.DeadlineExceeded)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Err: dekopin.RunSmokeChecks(context.Background(), server.Client(), server.URL, ar.checks),
+			})
+		})
+	}
+}
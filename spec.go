@@ -0,0 +1,212 @@
+package dekopin
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAX_CONTAINER_CONCURRENCY = 1000
+)
+
+// ServiceSpec is the desired configuration of the revisions dekopin creates.
+// Every field is optional, and only the fields that are set are applied on deploy.
+type ServiceSpec struct {
+	Env            map[string]string `yaml:"env,omitempty"`
+	CPU            string            `yaml:"cpu,omitempty"`
+	Memory         string            `yaml:"memory,omitempty"`
+	Concurrency    *int              `yaml:"concurrency,omitempty"`
+	MinInstances   *int              `yaml:"min_instances,omitempty"`
+	MaxInstances   *int              `yaml:"max_instances,omitempty"`
+	ServiceAccount string            `yaml:"service_account,omitempty"`
+	VPCConnector   string            `yaml:"vpc_connector,omitempty"`
+	Labels         map[string]string `yaml:"labels,omitempty"`
+
+	// Secrets maps an environment variable name or an absolute mount path to secret:version.
+	Secrets map[string]string `yaml:"secrets,omitempty"`
+}
+
+// Environment variables set by Cloud Run itself.
+var reservedEnvNames = []string{"PORT", "K_SERVICE", "K_REVISION", "K_CONFIGURATION"}
+
+var (
+	envNamePattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	cpuPattern            = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?|[0-9]+m)$`)
+	memoryPattern         = regexp.MustCompile(`^[0-9]+(Mi|Gi)$`)
+	serviceAccountPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]@[a-z0-9.-]+\.iam\.gserviceaccount\.com$|^[0-9]+-compute@developer\.gserviceaccount\.com$`)
+	labelKeyPattern       = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	labelValuePattern     = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+)
+
+// MergeServiceSpec returns base with the fields set in override applied on top.
+// Env and labels are merged key by key.
+func MergeServiceSpec(base *ServiceSpec, override *ServiceSpec) *ServiceSpec {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+
+	merged := *base
+	merged.Env = mergeStringMap(base.Env, override.Env)
+	merged.Labels = mergeStringMap(base.Labels, override.Labels)
+	merged.Secrets = mergeStringMap(base.Secrets, override.Secrets)
+	if override.CPU != "" {
+		merged.CPU = override.CPU
+	}
+	if override.Memory != "" {
+		merged.Memory = override.Memory
+	}
+	if override.Concurrency != nil {
+		merged.Concurrency = override.Concurrency
+	}
+	if override.MinInstances != nil {
+		merged.MinInstances = override.MinInstances
+	}
+	if override.MaxInstances != nil {
+		merged.MaxInstances = override.MaxInstances
+	}
+	if override.ServiceAccount != "" {
+		merged.ServiceAccount = override.ServiceAccount
+	}
+	if override.VPCConnector != "" {
+		merged.VPCConnector = override.VPCConnector
+	}
+
+	return &merged
+}
+
+func mergeStringMap(base map[string]string, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, override)
+	return merged
+}
+
+// Validate checks the spec values so that mistakes are reported before Cloud Run is called.
+func (s *ServiceSpec) Validate() error {
+	if s == nil {
+		return nil
+	}
+
+	errs := []error{}
+	for _, name := range slices.Sorted(maps.Keys(s.Env)) {
+		if !envNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("spec.env: invalid environment variable name %q", name))
+		}
+		if slices.Contains(reservedEnvNames, name) {
+			errs = append(errs, fmt.Errorf("spec.env: %s is reserved by Cloud Run", name))
+		}
+	}
+
+	if s.CPU != "" && !cpuPattern.MatchString(s.CPU) {
+		errs = append(errs, fmt.Errorf("spec.cpu: invalid value %q. Valid values: e.g. 1, 2, 0.5, 500m", s.CPU))
+	}
+
+	if s.Memory != "" && !memoryPattern.MatchString(s.Memory) {
+		errs = append(errs, fmt.Errorf("spec.memory: invalid value %q. Valid values: e.g. 512Mi, 2Gi", s.Memory))
+	}
+
+	if s.Concurrency != nil && (*s.Concurrency < 1 || *s.Concurrency > MAX_CONTAINER_CONCURRENCY) {
+		errs = append(errs, fmt.Errorf("spec.concurrency: must be between 1 and %d", MAX_CONTAINER_CONCURRENCY))
+	}
+
+	if s.MinInstances != nil && *s.MinInstances < 0 {
+		errs = append(errs, fmt.Errorf("spec.min_instances: must not be negative"))
+	}
+
+	if s.MaxInstances != nil && *s.MaxInstances < 1 {
+		errs = append(errs, fmt.Errorf("spec.max_instances: must be at least 1"))
+	}
+
+	if s.MinInstances != nil && s.MaxInstances != nil && *s.MinInstances > *s.MaxInstances {
+		errs = append(errs, fmt.Errorf("spec.min_instances: must not be greater than max_instances"))
+	}
+
+	if s.ServiceAccount != "" && !serviceAccountPattern.MatchString(s.ServiceAccount) {
+		errs = append(errs, fmt.Errorf("spec.service_account: invalid service account email %q", s.ServiceAccount))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(s.Labels)) {
+		if !labelKeyPattern.MatchString(key) || !labelValuePattern.MatchString(s.Labels[key]) {
+			errs = append(errs, fmt.Errorf("spec.labels: invalid label %s=%s", key, s.Labels[key]))
+		}
+	}
+
+	if err := validateSecrets(s.Secrets); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// DeployArgs returns the gcloud run deploy arguments that apply the spec.
+func (s *ServiceSpec) DeployArgs() []string {
+	if s == nil {
+		return nil
+	}
+
+	args := []string{}
+	if len(s.Env) > 0 {
+		args = append(args, "--set-env-vars", joinKeyValues(s.Env))
+	}
+	if s.CPU != "" {
+		args = append(args, "--cpu", s.CPU)
+	}
+	if s.Memory != "" {
+		args = append(args, "--memory", s.Memory)
+	}
+	if s.Concurrency != nil {
+		args = append(args, "--concurrency", strconv.Itoa(*s.Concurrency))
+	}
+	if s.MinInstances != nil {
+		args = append(args, "--min-instances", strconv.Itoa(*s.MinInstances))
+	}
+	if s.MaxInstances != nil {
+		args = append(args, "--max-instances", strconv.Itoa(*s.MaxInstances))
+	}
+	if s.ServiceAccount != "" {
+		args = append(args, "--service-account", s.ServiceAccount)
+	}
+	if s.VPCConnector != "" {
+		args = append(args, "--vpc-connector", s.VPCConnector)
+	}
+	if len(s.Labels) > 0 {
+		args = append(args, "--labels", joinKeyValues(s.Labels))
+	}
+	if len(s.Secrets) > 0 {
+		args = append(args, "--set-secrets", joinKeyValues(s.Secrets))
+	}
+
+	return args
+}
+
+// joinKeyValues joins KEY=VALUE pairs for gcloud, switching to gcloud's alternate
+// delimiter syntax when a value contains a comma.
+func joinKeyValues(values map[string]string) string {
+	pairs := make([]string, 0, len(values))
+	delimiter := ","
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if strings.Contains(values[key], ",") {
+			delimiter = "@@"
+		}
+		pairs = append(pairs, key+"="+values[key])
+	}
+
+	if delimiter == "," {
+		return strings.Join(pairs, delimiter)
+	}
+	return "^" + delimiter + "^" + strings.Join(pairs, delimiter)
+}
@@ -0,0 +1,144 @@
+package dekopin_test
+
+import (
+	"testing"
+
+	"github.com/iwashi623/dekopin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceSpec_Validate(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		spec *dekopin.ServiceSpec
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_valid_spec": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					spec: &dekopin.ServiceSpec{
+						Env:            map[string]string{"APP_ENV": "production"},
+						CPU:            "1",
+						Memory:         "512Mi",
+						Concurrency:    lo.ToPtr(80),
+						MinInstances:   lo.ToPtr(1),
+						MaxInstances:   lo.ToPtr(10),
+						ServiceAccount: "my-service@my-project.iam.gserviceaccount.com",
+						Labels:         map[string]string{"team": "backend"},
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_nil_spec": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_reserved_env_and_invalid_memory": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					spec: &dekopin.ServiceSpec{
+						Env:    map[string]string{"PORT": "8080"},
+						Memory: "512MB",
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "PORT is reserved")
+				assert.ErrorContains(t, result.Err, "spec.memory")
+			},
+		},
+		"error_min_instances_greater_than_max_instances": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					spec: &dekopin.ServiceSpec{
+						MinInstances: lo.ToPtr(5),
+						MaxInstances: lo.ToPtr(2),
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "spec.min_instances")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			err := ar.spec.Validate()
+			c.Assert(t, ar, TestResult{
+				Err: err,
+			})
+		})
+	}
+}
+
+func TestServiceSpec_DeployArgs(t *testing.T) {
+	type TestResult struct {
+		Args []string
+	}
+
+	type ArrangeResult struct {
+		spec *dekopin.ServiceSpec
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_service_spec_overrides_the_top_level_spec": {
+			Arrange: func() ArrangeResult {
+				base := &dekopin.ServiceSpec{
+					Env:          map[string]string{"APP_ENV": "production", "LOG_LEVEL": "info"},
+					Memory:       "512Mi",
+					MaxInstances: lo.ToPtr(10),
+				}
+				override := &dekopin.ServiceSpec{
+					Env:          map[string]string{"LOG_LEVEL": "debug"},
+					MinInstances: lo.ToPtr(0),
+				}
+				return ArrangeResult{
+					spec: dekopin.MergeServiceSpec(base, override),
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, []string{
+					"--set-env-vars", "APP_ENV=production,LOG_LEVEL=debug",
+					"--memory", "512Mi",
+					"--min-instances", "0",
+					"--max-instances", "10",
+				}, result.Args)
+			},
+		},
+		"success_env_value_with_comma_uses_alternate_delimiter": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					spec: &dekopin.ServiceSpec{
+						Env: map[string]string{"HOSTS": "a,b", "PORT_NAME": "http"},
+					},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, []string{"--set-env-vars", "^@@^HOSTS=a,b@@PORT_NAME=http"}, result.Args)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Args: ar.spec.DeployArgs(),
+			})
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package dekopin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the service URL, latest revision and traffic",
+	RunE:  statusCommand,
+}
+
+func statusCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return status(ctx, gc, os.Stdout)
+		})
+	})
+}
+
+func status(ctx context.Context, gc GCloud, w io.Writer) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get service: %w", err)
+	}
+
+	// Build the whole block first so concurrent services do not interleave their output.
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Service:         %s\n", path.Base(service.Name))
+	fmt.Fprintf(&buf, "Region:          %s\n", opt.Region)
+	fmt.Fprintf(&buf, "URL:             %s\n", service.Uri)
+	fmt.Fprintf(&buf, "Latest revision: %s\n", path.Base(service.LatestReadyRevision))
+
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REVISION\tPERCENT\tTAG\tURL")
+	for _, t := range service.TrafficStatuses {
+		revision := t.Revision
+		if revision == "" {
+			revision = path.Base(service.LatestReadyRevision)
+		}
+		fmt.Fprintf(tw, "%s\t%d%%\t%s\t%s\n", revision, t.Percent, t.Tag, t.Uri)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+
+	if err := writeSecretRefs(&buf, RevisionSecretRefs(service.GetTemplate().GetContainers(), service.GetTemplate().GetVolumes())); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+	fmt.Fprintln(&buf)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+
+	return nil
+}
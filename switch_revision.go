@@ -3,7 +3,9 @@ package dekopin
 import (
 	"context"
 	"fmt"
+	"os"
 
+	"cloud.google.com/go/run/apiv2/runpb"
 	"github.com/spf13/cobra"
 )
 
@@ -12,9 +14,18 @@ const (
 )
 
 var srDeployCmd = &cobra.Command{
-	Use:   "sr-deploy",
-	Short: "Switch Revision Deploy(Deploy new revision with revision name)",
-	RunE:  switchRevisionDeployCommand,
+	Use:     "sr-deploy",
+	Short:   "Switch Revision Deploy(Deploy new revision with revision name)",
+	PreRunE: srDeployPreRun,
+	RunE:    switchRevisionDeployCommand,
+}
+
+func srDeployPreRun(cmd *cobra.Command, args []string) error {
+	if _, err := getWatchFlag(cmd.Context()); err != nil {
+		return err
+	}
+
+	return checkFreeze(cmd)
 }
 
 func switchRevisionDeployCommand(cmd *cobra.Command, args []string) error {
@@ -34,10 +45,27 @@ func switchRevisionDeployCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get revision flag: %w", err)
 	}
 
-	return switchRevisionDeploy(ctx, gc, revision)
+	watch, err := getWatchFlag(ctx)
+	if err != nil {
+		return err
+	}
+
+	if watch {
+		ctx, err = WithWatchTimeout(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx = WithHookVars(ctx, map[string]string{"REVISION": revision})
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return switchRevisionDeploy(ctx, gc, revision, watch)
+		})
+	})
 }
 
-func switchRevisionDeploy(ctx context.Context, gc GCloud, revision string) error {
+func switchRevisionDeploy(ctx context.Context, gc GCloud, revision string, watch bool) error {
 	if revision != SWITCH_REVISION_DEFAULT_REVISION {
 		_, err := gc.GetRevision(ctx, revision)
 		if err != nil {
@@ -45,9 +73,22 @@ func switchRevisionDeploy(ctx context.Context, gc GCloud, revision string) error
 		}
 	}
 
+	var before *runpb.Service
+	if watch {
+		var err error
+		before, err = gc.GetService(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get service: %w", err)
+		}
+	}
+
 	if err := gc.UpdateTrafficToRevision(ctx, revision); err != nil {
 		return fmt.Errorf("failed to update traffic to latest revision: %w", err)
 	}
 
+	if watch {
+		return watchAfterSwitch(ctx, gc, before, revision)
+	}
+
 	return nil
 }
@@ -3,9 +3,10 @@ package dekopin
 import (
 	"context"
 	"fmt"
+	"os"
+	"path"
 	"slices"
 
-	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
@@ -31,7 +32,11 @@ func stDeployPreRun(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	return nil
+	if _, err := getWatchFlag(cmd.Context()); err != nil {
+		return err
+	}
+
+	return checkFreeze(cmd)
 }
 
 func switchTagDeployCommand(cmd *cobra.Command, args []string) error {
@@ -47,30 +52,64 @@ func switchTagDeployCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get tag flag: %w", err)
 	}
 
-	rt, err := CreateRevisionTagName(ctx, tag)
+	gc, err := GetGCloud(cmd.Context())
 	if err != nil {
-		return fmt.Errorf("failed to get tag name: %w", err)
+		return fmt.Errorf("failed to get gcloud command: %w", err)
 	}
 
-	gc, err := GetGCloud(cmd.Context())
+	removeTags, err := NewTagRemoval(dekopinCmd)
 	if err != nil {
-		return fmt.Errorf("failed to get gcloud command: %w", err)
+		return err
 	}
 
-	removeTags, err := dekopinCmd.GetRemoveTagsByFlag()
+	watch, err := getWatchFlag(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get remove-tags flag: %w", err)
+		return err
+	}
+
+	if watch {
+		ctx, err = WithWatchTimeout(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
-	return switchTagDeploy(cmd.Context(), gc, rt, removeTags)
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		rt, err := CreateRevisionTagName(ctx, tag)
+		if err != nil {
+			return fmt.Errorf("failed to get tag name: %w", err)
+		}
+
+		serviceRemoveTags := removeTags
+		if service, ok := GetServiceConfig(ctx); ok {
+			serviceRemoveTags.All = serviceRemoveTags.All || service.RemoveTags
+		}
+
+		ctx = WithHookVars(ctx, map[string]string{"TAG": rt})
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			return switchTagDeploy(ctx, gc, rt, serviceRemoveTags, watch)
+		})
+	})
 }
 
-func switchTagDeploy(ctx context.Context, gc GCloud, tag string, removeTags bool) error {
-	tags, err := gc.GetActiveRevisionTags(ctx)
+func switchTagDeploy(ctx context.Context, gc GCloud, tag string, removeTags TagRemoval, watch bool) error {
+	before, err := gc.GetService(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get service: %w", err)
 	}
 
+	tags := []string{}
+	revision := ""
+	for _, t := range before.GetTraffic() {
+		if t.GetTag() == "" {
+			continue
+		}
+		tags = append(tags, t.GetTag())
+		if t.GetTag() == tag {
+			revision = path.Base(t.GetRevision())
+		}
+	}
+
 	if !slices.Contains(tags, tag) {
 		return fmt.Errorf("active tag %s not found", tag)
 	}
@@ -79,15 +118,12 @@ func switchTagDeploy(ctx context.Context, gc GCloud, tag string, removeTags bool
 		return fmt.Errorf("failed to update traffic to revision tag: %w", err)
 	}
 
-	if removeTags {
-		filteredTags := lo.Filter(tags, func(t string, _ int) bool {
-			return t != tag
-		})
-
-		if err := gc.RemoveRevisionTags(ctx, filteredTags); err != nil {
-			return fmt.Errorf("failed to remove revision tags: %w", err)
+	// Tags are only removed once the revision proved healthy, so a rollback still has them.
+	if watch {
+		if err := watchAfterSwitch(ctx, gc, before, revision); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return removeRevisionTags(ctx, gc, removeTags, tag)
 }
@@ -0,0 +1,716 @@
+package dekopin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SCM_GITHUB = "github"
+
+	TAG_GC_ACTION_KEEP   = "keep"
+	TAG_GC_ACTION_REMOVE = "remove"
+)
+
+// TagRecord is a tag of the service with the revision it points to.
+type TagRecord struct {
+	Tag      string    `json:"tag"`
+	Revision string    `json:"revision"`
+	URL      string    `json:"url,omitempty"`
+	Image    string    `json:"image,omitempty"`
+	Traffic  int32     `json:"traffic"`
+	Created  time.Time `json:"created"`
+}
+
+// MatchTagPattern matches a tag against a glob pattern, e.g. tag-refs-pull-*.
+func MatchTagPattern(pattern string, tag string) bool {
+	matched, err := path.Match(pattern, tag)
+	return err == nil && matched
+}
+
+// IsProtectedTag reports whether the tag matches one of the protected_tags.
+func IsProtectedTag(protected []string, tag string) bool {
+	return slices.ContainsFunc(protected, func(pattern string) bool {
+		return MatchTagPattern(pattern, tag)
+	})
+}
+
+// CheckProtectedTags fails if assigning the tags, keyed by tag name, would move a protected tag
+// that already exists to another revision. Creating a protected tag is allowed. A revision of
+// LATEST means the latest ready revision.
+func CheckProtectedTags(service *runpb.Service, protected []string, tags map[string]string) error {
+	latest := path.Base(service.GetLatestReadyRevision())
+	for _, t := range service.GetTrafficStatuses() {
+		revision, ok := tags[t.GetTag()]
+		if t.GetTag() == "" || !ok || !IsProtectedTag(protected, t.GetTag()) {
+			continue
+		}
+
+		current := path.Base(t.GetRevision())
+		if t.GetType() == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			current = latest
+		}
+		if revision == SWITCH_REVISION_DEFAULT_REVISION {
+			revision = latest
+		}
+		if current != revision {
+			return fmt.Errorf("tag %s is protected. Pass --force to reassign it", t.GetTag())
+		}
+	}
+	return nil
+}
+
+type forceKey struct{}
+
+// SetForce lets the commands run with ctx reassign protected tags, as --force does.
+func SetForce(ctx context.Context, force bool) context.Context {
+	return context.WithValue(ctx, forceKey{}, force)
+}
+
+func IsForced(ctx context.Context) bool {
+	force, _ := ctx.Value(forceKey{}).(bool)
+	return force
+}
+
+func validateProtectedTags(protected []string) error {
+	errs := []error{}
+	for i, pattern := range protected {
+		if pattern == "" {
+			errs = append(errs, fmt.Errorf("protected_tags[%d]: must not be empty", i))
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("protected_tags[%d]: invalid pattern %q", i, pattern))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TagRemoval selects the tags removed after deploy, create-tag and st-deploy. The tag of the
+// command and the protected_tags are never removed.
+type TagRemoval struct {
+	// All removes every other tag, as --remove-tags does.
+	All bool
+	// Matching is a glob, or a regular expression between slashes such as /^pr-[0-9]+$/.
+	Matching  string
+	Keep      []string
+	OlderThan time.Duration
+}
+
+// NewTagRemoval reads --remove-tags and the options that narrow it down.
+func NewTagRemoval(cmd DekopinCommand) (TagRemoval, error) {
+	all, err := cmd.GetRemoveTagsByFlag()
+	if err != nil {
+		return TagRemoval{}, fmt.Errorf("failed to get remove-tags flag: %w", err)
+	}
+
+	matching, err := cmd.GetRemoveTagsMatchingByFlag()
+	if err != nil {
+		return TagRemoval{}, err
+	}
+
+	keep, err := cmd.GetKeepTagsByFlag()
+	if err != nil {
+		return TagRemoval{}, err
+	}
+
+	olderThan, err := cmd.GetRemoveTagsOlderThanByFlag()
+	if err != nil {
+		return TagRemoval{}, err
+	}
+
+	removal := TagRemoval{All: all, Matching: matching, Keep: keep, OlderThan: olderThan}
+	if err := removal.Validate(); err != nil {
+		return TagRemoval{}, err
+	}
+	return removal, nil
+}
+
+// Enabled reports whether any tag is to be removed. --keep-tags alone removes nothing.
+func (r TagRemoval) Enabled() bool {
+	return r.All || r.Matching != "" || r.OlderThan > 0
+}
+
+func (r TagRemoval) Validate() error {
+	errs := []error{}
+	if _, err := matchTagExpr(r.Matching, ""); r.Matching != "" && err != nil {
+		errs = append(errs, fmt.Errorf("invalid remove-tags-matching %q: %w", r.Matching, err))
+	}
+	for _, keep := range r.Keep {
+		if _, err := path.Match(keep, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid keep-tags pattern %q", keep))
+		}
+	}
+	if r.OlderThan < 0 {
+		errs = append(errs, fmt.Errorf("remove-tags-older-than must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Select returns the tags to remove. Matching and OlderThan both apply when both are set.
+func (r TagRemoval) Select(tags []TagRecord, current string, now time.Time) ([]string, error) {
+	remove := []string{}
+	if !r.Enabled() {
+		return remove, nil
+	}
+
+	for _, t := range tags {
+		if t.Tag == current || slices.ContainsFunc(r.Keep, func(keep string) bool { return MatchTagPattern(keep, t.Tag) }) {
+			continue
+		}
+		if r.Matching != "" {
+			matched, err := matchTagExpr(r.Matching, t.Tag)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		if r.OlderThan > 0 && now.Sub(t.Created) <= r.OlderThan {
+			continue
+		}
+		remove = append(remove, t.Tag)
+	}
+
+	return remove, nil
+}
+
+// matchTagExpr matches a tag against a glob, or a regular expression between slashes.
+func matchTagExpr(expr string, tag string) (bool, error) {
+	if len(expr) >= 2 && strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/") {
+		re, err := regexp.Compile(expr[1 : len(expr)-1])
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(tag), nil
+	}
+	return path.Match(expr, tag)
+}
+
+// removeRevisionTags removes the tags removal selects on the service of ctx, except current.
+func removeRevisionTags(ctx context.Context, gc GCloud, removal TagRemoval, current string) error {
+	if !removal.Enabled() {
+		return nil
+	}
+
+	tags := []TagRecord{}
+	if removal.OlderThan > 0 {
+		records, err := gc.ListTags(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list tags: %w", err)
+		}
+		tags = records
+	} else {
+		// Only the age needs the revisions.
+		names, err := gc.GetActiveRevisionTags(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get active revision tags: %w", err)
+		}
+		for _, name := range names {
+			tags = append(tags, TagRecord{Tag: name})
+		}
+	}
+
+	remove, err := removal.Select(tags, current, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := gc.RemoveRevisionTags(ctx, remove); err != nil {
+		return fmt.Errorf("failed to remove revision tags: %w", err)
+	}
+	return nil
+}
+
+// SCMChecker tells whether the pull request behind a preview tag is closed.
+type SCMChecker interface {
+	PullRequestClosed(ctx context.Context, number int) (bool, error)
+}
+
+// scmCheckers builds the checker for tags gc --scm, keyed by the SCM name.
+var scmCheckers = map[string]func(ctx context.Context) (SCMChecker, error){
+	SCM_GITHUB: func(ctx context.Context) (SCMChecker, error) {
+		return NewGitHubClient()
+	},
+}
+
+// RegisterSCMChecker makes a source control system available to tags gc --scm.
+func RegisterSCMChecker(name string, factory func(ctx context.Context) (SCMChecker, error)) {
+	scmCheckers[name] = factory
+}
+
+func NewSCMChecker(ctx context.Context, name string) (SCMChecker, error) {
+	factory, ok := scmCheckers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scm %q. Valid values: %s", name, strings.Join(slices.Sorted(maps.Keys(scmCheckers)), ", "))
+	}
+	return factory(ctx)
+}
+
+func (c *GitHubClient) PullRequestClosed(ctx context.Context, number int) (bool, error) {
+	var pull struct {
+		State string `json:"state"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("pulls/%d", number), nil, &pull); err != nil {
+		return false, fmt.Errorf("failed to get pull request %d: %w", number, err)
+	}
+	return pull.State == "closed", nil
+}
+
+// TagPullRequestNumber returns the first number in a tag, such as 12 in tag-refs-pull-12-merge.
+func TagPullRequestNumber(tag string) (int, bool) {
+	for _, part := range strings.Split(tag, "-") {
+		if n, err := strconv.Atoi(part); err == nil && n > 0 {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// TagGCOptions select the tags tags gc removes. Zero values disable a rule.
+type TagGCOptions struct {
+	TTL           time.Duration
+	ClosedPattern string
+	MaxCount      int
+	Protected     []string
+}
+
+// TagGCDecision is what tags gc does with one tag, and why.
+type TagGCDecision struct {
+	TagRecord
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// PlanTagGC decides which tags to remove. Protected tags and tags carrying traffic are always kept.
+// closed reports whether the pull request of a tag matching ClosedPattern is closed.
+func PlanTagGC(tags []TagRecord, opts TagGCOptions, now time.Time, closed func(tag string) (bool, error)) ([]TagGCDecision, error) {
+	tags = slices.Clone(tags)
+	slices.SortStableFunc(tags, func(a, b TagRecord) int {
+		return b.Created.Compare(a.Created)
+	})
+
+	decisions := make([]TagGCDecision, len(tags))
+	errs := []error{}
+	for i, t := range tags {
+		d := TagGCDecision{TagRecord: t, Action: TAG_GC_ACTION_KEEP}
+		switch {
+		case IsProtectedTag(opts.Protected, t.Tag):
+			d.Reason = "protected"
+		case t.Traffic > 0:
+			d.Reason = "serving traffic"
+		case opts.TTL > 0 && now.Sub(t.Created) > opts.TTL:
+			d.Action, d.Reason = TAG_GC_ACTION_REMOVE, "older than "+opts.TTL.String()
+		case opts.ClosedPattern != "" && MatchTagPattern(opts.ClosedPattern, t.Tag):
+			isClosed, err := closed(t.Tag)
+			if err != nil {
+				// A tag whose pull request cannot be checked is kept.
+				errs = append(errs, fmt.Errorf("tag %s: %w", t.Tag, err))
+				d.Reason = "pull request unknown"
+			} else if isClosed {
+				d.Action, d.Reason = TAG_GC_ACTION_REMOVE, "pull request closed"
+			}
+		}
+		decisions[i] = d
+	}
+
+	if opts.MaxCount > 0 {
+		kept := 0
+		for _, d := range decisions {
+			if d.Action == TAG_GC_ACTION_KEEP {
+				kept++
+			}
+		}
+		// The oldest tags go first.
+		for i := len(decisions) - 1; i >= 0 && kept > opts.MaxCount; i-- {
+			d := &decisions[i]
+			if d.Action == TAG_GC_ACTION_KEEP && d.Reason == "" {
+				d.Action, d.Reason = TAG_GC_ACTION_REMOVE, fmt.Sprintf("more than %d tags", opts.MaxCount)
+				kept--
+			}
+		}
+	}
+
+	return decisions, errors.Join(errs...)
+}
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "Manage revision tags",
+}
+
+var tagsGCCmd = &cobra.Command{
+	Use:     "gc",
+	Short:   "Remove stale revision tags",
+	PreRunE: tagsGCPreRun,
+	RunE:    tagsGCCommand,
+}
+
+func getTagGCOptions(ctx context.Context) (TagGCOptions, error) {
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return TagGCOptions{}, fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return TagGCOptions{}, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	ttl, err := dekopinCmd.GetTTLByFlag()
+	if err != nil {
+		return TagGCOptions{}, err
+	}
+
+	closedPattern, err := dekopinCmd.GetClosedPatternByFlag()
+	if err != nil {
+		return TagGCOptions{}, err
+	}
+
+	maxCount, err := dekopinCmd.GetMaxCountByFlag()
+	if err != nil {
+		return TagGCOptions{}, err
+	}
+
+	return TagGCOptions{TTL: ttl, ClosedPattern: closedPattern, MaxCount: maxCount, Protected: opt.ProtectedTags}, nil
+}
+
+func tagsGCPreRun(cmd *cobra.Command, args []string) error {
+	opts, err := getTagGCOptions(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if opts.TTL < 0 || opts.MaxCount < 0 {
+		return fmt.Errorf("ttl and max-count must not be negative")
+	}
+
+	if opts.TTL == 0 && opts.ClosedPattern == "" && opts.MaxCount == 0 {
+		return fmt.Errorf("one of ttl, closed-pattern or max-count is required")
+	}
+
+	if _, err := path.Match(opts.ClosedPattern, ""); err != nil {
+		return fmt.Errorf("invalid closed-pattern %q", opts.ClosedPattern)
+	}
+
+	return nil
+}
+
+func tagsGCCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	opts, err := getTagGCOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	dryRun, err := dekopinCmd.GetDryRunByFlag()
+	if err != nil {
+		return err
+	}
+
+	var checker SCMChecker
+	if opts.ClosedPattern != "" {
+		scm, err := dekopinCmd.GetSCMByFlag()
+		if err != nil {
+			return err
+		}
+		if checker, err = NewSCMChecker(ctx, scm); err != nil {
+			return fmt.Errorf("failed to create scm checker: %w", err)
+		}
+	}
+
+	// Pull requests are shared by the services and regions.
+	closedPRs := map[int]bool{}
+	var closedMu sync.Mutex
+	closed := func(tag string) (bool, error) {
+		number, ok := TagPullRequestNumber(tag)
+		if !ok {
+			return false, fmt.Errorf("no pull request number in the tag")
+		}
+
+		closedMu.Lock()
+		defer closedMu.Unlock()
+		if isClosed, ok := closedPRs[number]; ok {
+			return isClosed, nil
+		}
+		isClosed, err := checker.PullRequestClosed(ctx, number)
+		if err != nil {
+			return false, err
+		}
+		closedPRs[number] = isClosed
+		return isClosed, nil
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			tags, err := gc.ListTags(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list tags: %w", err)
+			}
+
+			decisions, err := PlanTagGC(tags, opts, time.Now(), closed)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", err)
+			}
+
+			if err := writeTagGC(os.Stdout, decisions, dryRun); err != nil {
+				return err
+			}
+
+			remove := []string{}
+			for _, d := range decisions {
+				if d.Action == TAG_GC_ACTION_REMOVE {
+					remove = append(remove, d.Tag)
+				}
+			}
+			if dryRun || len(remove) == 0 {
+				return nil
+			}
+
+			return gc.RemoveRevisionTags(ctx, remove)
+		})
+	})
+}
+
+func writeTagGC(w io.Writer, decisions []TagGCDecision, dryRun bool) error {
+	var buf bytes.Buffer
+	if len(decisions) == 0 {
+		fmt.Fprintln(&buf, "No tags")
+	} else {
+		tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TAG\tREVISION\tCREATED\tTRAFFIC\tACTION\tREASON")
+		for _, d := range decisions {
+			action := d.Action
+			if dryRun && action == TAG_GC_ACTION_REMOVE {
+				action = "would remove"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d%%\t%s\t%s\n", d.Tag, d.Revision, d.Created.Format(time.RFC3339), d.Traffic, action, d.Reason)
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("failed to write tags: %w", err)
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write tags: %w", err)
+	}
+
+	return nil
+}
+
+const (
+	TAG_SORT_TAG     = "tag"
+	TAG_SORT_AGE     = "age"
+	TAG_SORT_TRAFFIC = "traffic"
+)
+
+var tagSorts = []string{TAG_SORT_TAG, TAG_SORT_AGE, TAG_SORT_TRAFFIC}
+
+var tagsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the revision tags with their revision, age, image, traffic and URL",
+	PreRunE: tagsListPreRun,
+	RunE:    tagsListCommand,
+}
+
+// SelectTags returns the tags matching filter, a glob or a regular expression between slashes,
+// sorted by tag name, age (newest first) or traffic (highest first). serving keeps only the tags
+// whose revision serves traffic.
+func SelectTags(tags []TagRecord, filter string, serving bool, sortBy string) ([]TagRecord, error) {
+	selected := []TagRecord{}
+	for _, t := range tags {
+		if serving && t.Traffic == 0 {
+			continue
+		}
+		if filter != "" {
+			matched, err := matchTagExpr(filter, t.Tag)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %q: %w", filter, err)
+			}
+			if !matched {
+				continue
+			}
+		}
+		selected = append(selected, t)
+	}
+
+	slices.SortStableFunc(selected, func(a, b TagRecord) int {
+		switch sortBy {
+		case TAG_SORT_AGE:
+			if c := b.Created.Compare(a.Created); c != 0 {
+				return c
+			}
+		case TAG_SORT_TRAFFIC:
+			if c := int(b.Traffic) - int(a.Traffic); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	return selected, nil
+}
+
+// FormatAge rounds an age to minutes, hours or days, e.g. 3d.
+func FormatAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(age.Hours()/24))
+}
+
+func tagsListPreRun(cmd *cobra.Command, args []string) error {
+	dekopinCmd, err := GetDekopinCommand(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	output, err := dekopinCmd.GetOutputByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+
+	if output != OUTPUT_TEXT && output != OUTPUT_JSON {
+		return fmt.Errorf("invalid output format. Valid values: text, json")
+	}
+
+	sortBy, err := dekopinCmd.GetSortByFlag()
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(tagSorts, sortBy) {
+		return fmt.Errorf("invalid sort. Valid values: %s", strings.Join(tagSorts, ", "))
+	}
+
+	filter, err := dekopinCmd.GetFilterByFlag()
+	if err != nil {
+		return err
+	}
+
+	if _, err := matchTagExpr(filter, ""); filter != "" && err != nil {
+		return fmt.Errorf("invalid filter %q: %w", filter, err)
+	}
+
+	return nil
+}
+
+func tagsListCommand(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	gc, err := GetGCloud(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gcloud command: %w", err)
+	}
+
+	output, err := dekopinCmd.GetOutputByFlag()
+	if err != nil {
+		return fmt.Errorf("failed to get output flag: %w", err)
+	}
+
+	sortBy, err := dekopinCmd.GetSortByFlag()
+	if err != nil {
+		return err
+	}
+
+	filter, err := dekopinCmd.GetFilterByFlag()
+	if err != nil {
+		return err
+	}
+
+	serving, err := dekopinCmd.GetServingByFlag()
+	if err != nil {
+		return err
+	}
+
+	return RunForEachService(ctx, os.Stderr, func(ctx context.Context) error {
+		return RunForEachRegion(ctx, false, func(ctx context.Context) error {
+			tags, err := gc.ListTags(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list tags: %w", err)
+			}
+
+			tags, err = SelectTags(tags, filter, serving, sortBy)
+			if err != nil {
+				return err
+			}
+
+			return writeTags(ctx, os.Stdout, tags, output, time.Now())
+		})
+	})
+}
+
+func writeTags(ctx context.Context, w io.Writer, tags []TagRecord, output string, now time.Time) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if output == OUTPUT_JSON {
+		if err := json.NewEncoder(&buf).Encode(map[string]any{
+			"service": opt.Service,
+			"region":  opt.Region,
+			"tags":    tags,
+		}); err != nil {
+			return fmt.Errorf("failed to write tags: %w", err)
+		}
+	} else {
+		fmt.Fprintf(&buf, "Service: %s (%s)\n", opt.Service, opt.Region)
+		if len(tags) == 0 {
+			fmt.Fprintln(&buf, "No tags")
+		} else {
+			tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TAG\tREVISION\tAGE\tTRAFFIC\tIMAGE\tURL")
+			for _, t := range tags {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d%%\t%s\t%s\n", t.Tag, t.Revision, FormatAge(now.Sub(t.Created)), t.Traffic, t.Image, t.URL)
+			}
+			if err := tw.Flush(); err != nil {
+				return fmt.Errorf("failed to write tags: %w", err)
+			}
+		}
+		fmt.Fprintln(&buf)
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write tags: %w", err)
+	}
+
+	return nil
+}
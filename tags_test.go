@@ -0,0 +1,508 @@
+package dekopin_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/iwashi623/dekopin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanTagGC(t *testing.T) {
+	type TestResult struct {
+		Decisions []dekopin.TagGCDecision
+		Err       error
+	}
+
+	type ArrangeResult struct {
+		tags   []dekopin.TagRecord
+		opts   dekopin.TagGCOptions
+		closed map[string]bool
+	}
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+	actions := func(decisions []dekopin.TagGCDecision) map[string]string {
+		m := map[string]string{}
+		for _, d := range decisions {
+			m[d.Tag] = d.Action + ": " + d.Reason
+		}
+		return m
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_ttl_keeps_protected_and_serving_tags": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					tags: []dekopin.TagRecord{
+						{Tag: "stable", Revision: "app-001", Created: daysAgo(30)},
+						{Tag: "release", Revision: "app-002", Created: daysAgo(20), Traffic: 100},
+						{Tag: "old-preview", Revision: "app-003", Created: daysAgo(10)},
+						{Tag: "new-preview", Revision: "app-004", Created: daysAgo(1)},
+					},
+					opts: dekopin.TagGCOptions{TTL: 7 * 24 * time.Hour, Protected: []string{"stable"}},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, map[string]string{
+					"stable":      "keep: protected",
+					"release":     "keep: serving traffic",
+					"old-preview": "remove: older than 168h0m0s",
+					"new-preview": "keep: ",
+				}, actions(result.Decisions))
+			},
+		},
+		"success_closed_pull_requests": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					tags: []dekopin.TagRecord{
+						{Tag: "tag-refs-pull-1-merge", Created: daysAgo(3)},
+						{Tag: "tag-refs-pull-2-merge", Created: daysAgo(2)},
+						{Tag: "tag-refs-heads-main", Created: daysAgo(1)},
+					},
+					opts:   dekopin.TagGCOptions{ClosedPattern: "tag-refs-pull-*"},
+					closed: map[string]bool{"tag-refs-pull-1-merge": true, "tag-refs-pull-2-merge": false},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, map[string]string{
+					"tag-refs-pull-1-merge": "remove: pull request closed",
+					"tag-refs-pull-2-merge": "keep: ",
+					"tag-refs-heads-main":   "keep: ",
+				}, actions(result.Decisions))
+			},
+		},
+		"success_max_count_removes_the_oldest": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					tags: []dekopin.TagRecord{
+						{Tag: "a", Created: daysAgo(4)},
+						{Tag: "b", Created: daysAgo(3)},
+						{Tag: "qa", Created: daysAgo(5)},
+						{Tag: "c", Created: daysAgo(1)},
+					},
+					opts: dekopin.TagGCOptions{MaxCount: 2, Protected: []string{"q*"}},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"c", "b", "a", "qa"}, []string{result.Decisions[0].Tag, result.Decisions[1].Tag, result.Decisions[2].Tag, result.Decisions[3].Tag})
+				assert.Equal(t, map[string]string{
+					"a":  "remove: more than 2 tags",
+					"b":  "remove: more than 2 tags",
+					"c":  "keep: ",
+					"qa": "keep: protected",
+				}, actions(result.Decisions))
+			},
+		},
+		"error_unknown_pull_request_is_kept": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					tags: []dekopin.TagRecord{{Tag: "tag-refs-pull-9-merge", Created: daysAgo(1)}},
+					opts: dekopin.TagGCOptions{ClosedPattern: "tag-refs-pull-*", MaxCount: 1},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "tag tag-refs-pull-9-merge: not found")
+				assert.Equal(t, map[string]string{"tag-refs-pull-9-merge": "keep: pull request unknown"}, actions(result.Decisions))
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			closed := func(tag string) (bool, error) {
+				isClosed, ok := args.closed[tag]
+				if !ok {
+					return false, errors.New("not found")
+				}
+				return isClosed, nil
+			}
+			decisions, err := dekopin.PlanTagGC(args.tags, args.opts, now, closed)
+			tc.Assert(t, args, TestResult{Decisions: decisions, Err: err})
+		})
+	}
+}
+
+func TestTagPullRequestNumber(t *testing.T) {
+	type TestResult struct {
+		Number int
+		OK     bool
+	}
+
+	type ArrangeResult struct {
+		tag string
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_runner_tag": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tag: "tag-refs-pull-42-merge"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.True(t, result.OK)
+				assert.Equal(t, 42, result.Number)
+			},
+		},
+		"success_no_number": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tag: "tag-refs-heads-main"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.False(t, result.OK)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			number, ok := dekopin.TagPullRequestNumber(args.tag)
+			tc.Assert(t, args, TestResult{Number: number, OK: ok})
+		})
+	}
+}
+
+func TestIsProtectedTag(t *testing.T) {
+	type TestResult struct {
+		Protected bool
+	}
+
+	type ArrangeResult struct {
+		tag string
+	}
+
+	protected := []string{"stable", "release-*"}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_literal": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tag: "stable"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.True(t, result.Protected)
+			},
+		},
+		"success_glob": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tag: "release-v2"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.True(t, result.Protected)
+			},
+		},
+		"success_not_protected": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tag: "stable-preview"}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.False(t, result.Protected)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			tc.Assert(t, args, TestResult{Protected: dekopin.IsProtectedTag(protected, args.tag)})
+		})
+	}
+}
+
+func TestCheckProtectedTags(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		tags map[string]string
+	}
+
+	protected := []string{"stable", "blue"}
+	service := &runpb.Service{
+		LatestReadyRevision: "projects/p/locations/r/services/svc/revisions/svc-c",
+		TrafficStatuses: []*runpb.TrafficTargetStatus{
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "svc-a", Tag: "stable"},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "svc-b", Percent: 100, Tag: "blue"},
+			{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "svc-c", Tag: "green"},
+		},
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_a_new_protected_tag_may_be_created": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tags: map[string]string{"stable-v2": "svc-c"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_a_protected_tag_may_stay_on_its_revision": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tags: map[string]string{"stable": "svc-a"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_unprotected_tags_may_move": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tags: map[string]string{"green": "svc-a"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_moving_a_protected_tag": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tags: map[string]string{"green": "svc-a", "blue": "svc-c"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.EqualError(t, result.Err, "tag blue is protected. Pass --force to reassign it")
+			},
+		},
+		"error_moving_a_protected_tag_to_the_latest_revision": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{tags: map[string]string{"stable": "LATEST"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.EqualError(t, result.Err, "tag stable is protected. Pass --force to reassign it")
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			tc.Assert(t, args, TestResult{Err: dekopin.CheckProtectedTags(service, protected, args.tags)})
+		})
+	}
+}
+
+func TestTagRemovalSelect(t *testing.T) {
+	type TestResult struct {
+		Removed []string
+		Err     error
+	}
+
+	type ArrangeResult struct {
+		removal dekopin.TagRemoval
+	}
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tags := []dekopin.TagRecord{
+		{Tag: "release", Created: now.Add(-time.Hour)},
+		{Tag: "pr-1", Created: now.AddDate(0, 0, -10)},
+		{Tag: "pr-2", Created: now.Add(-time.Hour)},
+		{Tag: "pr-x", Created: now.AddDate(0, 0, -10)},
+		{Tag: "qa", Created: now.AddDate(0, 0, -30)},
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_all_except_current": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{removal: dekopin.TagRemoval{All: true}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"pr-1", "pr-2", "pr-x", "qa"}, result.Removed)
+			},
+		},
+		"success_glob_and_keep": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{removal: dekopin.TagRemoval{Matching: "pr-*", Keep: []string{"pr-2"}}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"pr-1", "pr-x"}, result.Removed)
+			},
+		},
+		"success_regex_and_older_than": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{removal: dekopin.TagRemoval{Matching: "/^pr-[0-9]+$/", OlderThan: 72 * time.Hour}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"pr-1"}, result.Removed)
+			},
+		},
+		"success_keep_alone_removes_nothing": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{removal: dekopin.TagRemoval{Keep: []string{"qa"}}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Empty(t, result.Removed)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			removed, err := args.removal.Select(tags, "release", now)
+			tc.Assert(t, args, TestResult{Removed: removed, Err: err})
+		})
+	}
+}
+
+func TestTagRemovalValidate(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		removal dekopin.TagRemoval
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_regex": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{removal: dekopin.TagRemoval{Matching: "/^pr-\\d+$/"}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_invalid_patterns": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{removal: dekopin.TagRemoval{Matching: "/pr-(/", Keep: []string{"qa-["}, OlderThan: -time.Hour}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, `invalid remove-tags-matching "/pr-(/"`)
+				assert.ErrorContains(t, result.Err, `invalid keep-tags pattern "qa-["`)
+				assert.ErrorContains(t, result.Err, "must not be negative")
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			tc.Assert(t, args, TestResult{Err: args.removal.Validate()})
+		})
+	}
+}
+
+func TestSelectTags(t *testing.T) {
+	type TestResult struct {
+		Tags []string
+		Err  error
+	}
+
+	type ArrangeResult struct {
+		filter  string
+		serving bool
+		sortBy  string
+	}
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tags := []dekopin.TagRecord{
+		{Tag: "release", Traffic: 90, Created: now.AddDate(0, 0, -3)},
+		{Tag: "pr-2", Created: now.Add(-time.Hour)},
+		{Tag: "canary", Traffic: 10, Created: now.Add(-2 * time.Hour)},
+		{Tag: "pr-1", Created: now.AddDate(0, 0, -1)},
+	}
+	names := func(tags []dekopin.TagRecord) []string {
+		n := []string{}
+		for _, t := range tags {
+			n = append(n, t.Tag)
+		}
+		return n
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_sort_by_tag": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{sortBy: dekopin.TAG_SORT_TAG}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"canary", "pr-1", "pr-2", "release"}, result.Tags)
+			},
+		},
+		"success_filter_sorted_by_age": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{filter: "pr-*", sortBy: dekopin.TAG_SORT_AGE}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"pr-2", "pr-1"}, result.Tags)
+			},
+		},
+		"success_serving_sorted_by_traffic": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{serving: true, sortBy: dekopin.TAG_SORT_TRAFFIC}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, []string{"release", "canary"}, result.Tags)
+			},
+		},
+		"error_invalid_regex": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{filter: "/pr-(/", sortBy: dekopin.TAG_SORT_TAG}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, `invalid filter "/pr-(/"`)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			selected, err := dekopin.SelectTags(tags, args.filter, args.serving, args.sortBy)
+			tc.Assert(t, args, TestResult{Tags: names(selected), Err: err})
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	type TestResult struct {
+		Age string
+	}
+
+	type ArrangeResult struct {
+		age time.Duration
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_minutes": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{age: 5*time.Minute + 30*time.Second}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, "5m", result.Age)
+			},
+		},
+		"success_hours_up_to_two_days": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{age: 26 * time.Hour}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, "26h", result.Age)
+			},
+		},
+		"success_days": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{age: 80 * time.Hour}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, "3d", result.Age)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.Arrange()
+			tc.Assert(t, args, TestResult{Age: dekopin.FormatAge(args.age)})
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package dekopin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+)
+
+const (
+	DEFAULT_WATCH_BAKE         = 5 * time.Minute
+	DEFAULT_WATCH_INTERVAL     = 30 * time.Second
+	DEFAULT_WATCH_MAX_FAILURES = 2
+
+	METRICS_PROVIDER_PROMETHEUS = "prometheus"
+)
+
+// WatchConfig describes the health checks polled after a traffic switch with --watch.
+type WatchConfig struct {
+	Bake     time.Duration `yaml:"bake,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// MaxFailures is the number of consecutive failed polls that triggers the rollback.
+	MaxFailures int           `yaml:"max_failures,omitempty"`
+	HTTP        []SmokeCheck  `yaml:"http,omitempty"`
+	Metrics     []MetricCheck `yaml:"metrics,omitempty"`
+}
+
+// MetricCheck fails when the query result leaves the [min, max] range. The query may use
+// {{project}}, {{region}}, {{service}} and {{revision}}.
+type MetricCheck struct {
+	Name     string   `yaml:"name,omitempty"`
+	Provider string   `yaml:"provider,omitempty"`
+	URL      string   `yaml:"url"`
+	Query    string   `yaml:"query"`
+	Min      *float64 `yaml:"min,omitempty"`
+	Max      *float64 `yaml:"max,omitempty"`
+}
+
+// MetricsProvider evaluates a query to a single value.
+type MetricsProvider interface {
+	Query(ctx context.Context, query string) (float64, error)
+}
+
+// metricsProviders builds a provider for a metric check, keyed by the provider name.
+var metricsProviders = map[string]func(check MetricCheck, client *http.Client) (MetricsProvider, error){
+	METRICS_PROVIDER_PROMETHEUS: func(check MetricCheck, client *http.Client) (MetricsProvider, error) {
+		return &PrometheusProvider{URL: check.URL, HTTPClient: client}, nil
+	},
+}
+
+// RegisterMetricsProvider makes a metrics backend available to watch.metrics[].provider.
+// The factory gets the HTTP client of the command.
+func RegisterMetricsProvider(name string, factory func(check MetricCheck, client *http.Client) (MetricsProvider, error)) {
+	metricsProviders[name] = factory
+}
+
+func (c *WatchConfig) Enabled() bool {
+	return c != nil && (len(c.HTTP) > 0 || len(c.Metrics) > 0)
+}
+
+func (c *WatchConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	errs := []error{}
+	if c.Bake < 0 || c.Interval < 0 || c.MaxFailures < 0 {
+		errs = append(errs, fmt.Errorf("watch: bake, interval and max_failures must not be negative"))
+	}
+
+	if err := (&SmokeConfig{Checks: c.HTTP}).Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("watch.http: %w", err))
+	}
+
+	for i, m := range c.Metrics {
+		if _, ok := metricsProviders[m.provider()]; !ok {
+			errs = append(errs, fmt.Errorf("watch.metrics[%d]: unknown provider %q. Valid values: %s", i, m.Provider, strings.Join(slices.Sorted(maps.Keys(metricsProviders)), ", ")))
+		}
+		if m.URL == "" || m.Query == "" {
+			errs = append(errs, fmt.Errorf("watch.metrics[%d]: url and query are required", i))
+		}
+		if m.Min == nil && m.Max == nil {
+			errs = append(errs, fmt.Errorf("watch.metrics[%d]: min or max is required", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *WatchConfig) bake() time.Duration {
+	if c.Bake == 0 {
+		return DEFAULT_WATCH_BAKE
+	}
+	return c.Bake
+}
+
+func (c *WatchConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return DEFAULT_WATCH_INTERVAL
+	}
+	return c.Interval
+}
+
+func (c *WatchConfig) maxFailures() int {
+	if c.MaxFailures == 0 {
+		return DEFAULT_WATCH_MAX_FAILURES
+	}
+	return c.MaxFailures
+}
+
+func (m MetricCheck) provider() string {
+	if m.Provider == "" {
+		return METRICS_PROVIDER_PROMETHEUS
+	}
+	return m.Provider
+}
+
+func (m MetricCheck) name() string {
+	if m.Name == "" {
+		return m.Query
+	}
+	return m.Name
+}
+
+// WatchHealth polls the checks until the bake period ends. It fails as soon as MaxFailures
+// consecutive polls failed. vars fill the placeholders of the metric queries.
+func WatchHealth(ctx context.Context, config *WatchConfig, client *http.Client, baseURL string, vars map[string]string) error {
+	replacer := strings.NewReplacer(placeholderPairs(vars)...)
+	deadline := time.Now().Add(config.bake())
+	failures := 0
+
+	for {
+		err := pollHealth(ctx, config, client, baseURL, replacer)
+		if err == nil {
+			failures = 0
+		} else {
+			failures++
+			fmt.Fprintf(Stdout(ctx), "Health check failed (%d/%d): %v\n", failures, config.maxFailures(), err)
+			if failures >= config.maxFailures() {
+				return err
+			}
+		}
+
+		if !time.Now().Add(config.interval()).Before(deadline) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(config.interval()):
+		}
+	}
+}
+
+func pollHealth(ctx context.Context, config *WatchConfig, client *http.Client, baseURL string, replacer *strings.Replacer) error {
+	errs := []error{}
+	for _, check := range config.HTTP {
+		if err := runSmokeCheck(ctx, client, baseURL, check); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", check.Path, err))
+		}
+	}
+
+	for _, m := range config.Metrics {
+		provider, err := metricsProviders[m.provider()](m, client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name(), err))
+			continue
+		}
+
+		value, err := provider.Query(ctx, replacer.Replace(m.Query))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name(), err))
+			continue
+		}
+
+		if m.Min != nil && value < *m.Min {
+			errs = append(errs, fmt.Errorf("%s: %g is below %g", m.name(), value, *m.Min))
+		}
+		if m.Max != nil && value > *m.Max {
+			errs = append(errs, fmt.Errorf("%s: %g is above %g", m.name(), value, *m.Max))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func placeholderPairs(vars map[string]string) []string {
+	pairs := []string{}
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	return pairs
+}
+
+// PrometheusProvider queries the instant query API of Prometheus or a compatible backend.
+type PrometheusProvider struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// Query returns the value of a scalar, or the first sample of a vector. An empty vector is 0,
+// so that a query counting errors passes while there are none.
+func (p *PrometheusProvider) Query(ctx context.Context, query string) (float64, error) {
+	endpoint := strings.TrimSuffix(p.URL, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create query request: %w", err)
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, MAX_SMOKE_BODY_SIZE)).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode query result: %w", err)
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("query failed: %s", body.Error)
+	}
+
+	var sample []any
+	switch body.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(body.Data.Result, &sample); err != nil {
+			return 0, fmt.Errorf("failed to decode scalar: %w", err)
+		}
+	case "vector":
+		var vector []struct {
+			Value []any `json:"value"`
+		}
+		if err := json.Unmarshal(body.Data.Result, &vector); err != nil {
+			return 0, fmt.Errorf("failed to decode vector: %w", err)
+		}
+		if len(vector) == 0 {
+			return 0, nil
+		}
+		sample = vector[0].Value
+	default:
+		return 0, fmt.Errorf("unsupported result type %q", body.Data.ResultType)
+	}
+
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("invalid sample %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid sample value %v", sample[1])
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// TrafficSplit returns the percent per revision of a service. The latest revision is keyed LATEST.
+func TrafficSplit(service *runpb.Service) map[string]int32 {
+	split := map[string]int32{}
+	for _, t := range service.GetTraffic() {
+		if t.GetPercent() == 0 {
+			continue
+		}
+		revision := path.Base(t.GetRevision())
+		if t.GetType() == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST {
+			revision = SWITCH_REVISION_DEFAULT_REVISION
+		}
+		split[revision] += t.GetPercent()
+	}
+	return split
+}
+
+// getWatchFlag returns --watch, which needs health checks in the configuration.
+func getWatchFlag(ctx context.Context) (bool, error) {
+	dekopinCmd, err := GetDekopinCommand(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get dekopin command: %w", err)
+	}
+
+	watch, err := dekopinCmd.GetWatchByFlag()
+	if err != nil {
+		return false, err
+	}
+
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if watch && !opt.Watch.Enabled() {
+		return false, fmt.Errorf("--watch requires watch.http or watch.metrics in the configuration file")
+	}
+
+	return watch, nil
+}
+
+// WithWatchTimeout returns the context for the regions of a watched command. Each region gets
+// TIMEOUT plus the bake period, as its watch outlasts TIMEOUT.
+func WithWatchTimeout(ctx context.Context) (context.Context, error) {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	if !opt.Watch.Enabled() {
+		return ctx, nil
+	}
+
+	return WithRegionTimeout(ctx, TIMEOUT+opt.Watch.bake()), nil
+}
+
+// watchAfterSwitch watches the service after its traffic was moved to revision and restores the
+// traffic of before when the health checks fail.
+func watchAfterSwitch(ctx context.Context, gc GCloud, before *runpb.Service, revision string) error {
+	opt, err := GetCmdOption(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cmdOption: %w", err)
+	}
+
+	service, err := gc.GetService(ctx)
+	if err != nil {
+		return err
+	}
+	if revision == SWITCH_REVISION_DEFAULT_REVISION {
+		revision = path.Base(service.GetLatestReadyRevision())
+	}
+
+	fmt.Fprintf(Stdout(ctx), "Watching %s for %s\n", revision, opt.Watch.bake())
+	watchErr := WatchHealth(ctx, opt.Watch, GetHTTPClient(ctx), service.GetUri(), map[string]string{
+		"project":  opt.Project,
+		"region":   opt.Region,
+		"service":  opt.Service,
+		"revision": revision,
+	})
+	if watchErr == nil {
+		fmt.Fprintf(Stdout(ctx), "%s stayed healthy\n", revision)
+		return nil
+	}
+
+	// The rollback must run even when the watch used up the deadline of the region.
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), TIMEOUT)
+	defer cancel()
+
+	fmt.Fprintln(Stdout(ctx), "Restoring the traffic from before the switch")
+	if err := gc.UpdateTrafficSplit(rollbackCtx, TrafficSplit(before)); err != nil {
+		return errors.Join(watchErr, fmt.Errorf("failed to restore traffic: %w", err))
+	}
+	Notify(ctx, EVENT_ROLLED_BACK, watchErr)
+
+	return fmt.Errorf("traffic was restored because %s became unhealthy: %w", revision, watchErr)
+}
@@ -0,0 +1,310 @@
+package dekopin_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/iwashi623/dekopin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+// newPrometheusStandIn answers instant queries like Prometheus. The error rate query returns
+// the values in order and repeats the last one.
+func newPrometheusStandIn(t *testing.T, errorRates ...string) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("query") {
+		case `error_rate{revision="svc-b"}`:
+			i := min(int(calls.Add(1))-1, len(errorRates)-1)
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"` + errorRates[i] + `"]}]}}`))
+		case "scalar(1)":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1"]}}`))
+		case "absent_metric":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","error":"parse error"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPrometheusProviderQuery(t *testing.T) {
+	type TestResult struct {
+		Value float64
+		Err   error
+	}
+
+	type ArrangeResult struct {
+		query string
+	}
+
+	server := newPrometheusStandIn(t, "0.25")
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_vector": {
+			Arrange: func() ArrangeResult { return ArrangeResult{query: `error_rate{revision="svc-b"}`} },
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, 0.25, result.Value)
+			},
+		},
+		"success_scalar": {
+			Arrange: func() ArrangeResult { return ArrangeResult{query: "scalar(1)"} },
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, 1.0, result.Value)
+			},
+		},
+		"success_empty_vector_is_zero": {
+			Arrange: func() ArrangeResult { return ArrangeResult{query: "absent_metric"} },
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Equal(t, 0.0, result.Value)
+			},
+		},
+		"error_query_failed": {
+			Arrange: func() ArrangeResult { return ArrangeResult{query: "invalid("} },
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "parse error")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			provider := &dekopin.PrometheusProvider{URL: server.URL, HTTPClient: server.Client()}
+			value, err := provider.Query(context.Background(), ar.query)
+			c.Assert(t, ar, TestResult{Value: value, Err: err})
+		})
+	}
+}
+
+func TestWatchHealth(t *testing.T) {
+	type TestResult struct {
+		Err error
+	}
+
+	type ArrangeResult struct {
+		config  *dekopin.WatchConfig
+		baseURL string
+	}
+
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.Write([]byte("ok"))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(app.Close)
+
+	errorRate := func(rates ...string) dekopin.MetricCheck {
+		return dekopin.MetricCheck{
+			Name:  "error rate",
+			URL:   newPrometheusStandIn(t, rates...).URL,
+			Query: `error_rate{revision="{{revision}}"}`,
+			Max:   lo.ToPtr(0.05),
+		}
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_healthy_for_the_bake_period": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					config: &dekopin.WatchConfig{
+						Bake:     50 * time.Millisecond,
+						Interval: 10 * time.Millisecond,
+						HTTP:     []dekopin.SmokeCheck{{Path: "/healthz"}},
+						Metrics:  []dekopin.MetricCheck{errorRate("0.01")},
+					},
+					baseURL: app.URL,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"success_single_failure_below_max_failures": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					config: &dekopin.WatchConfig{
+						Bake:     50 * time.Millisecond,
+						Interval: 10 * time.Millisecond,
+						Metrics:  []dekopin.MetricCheck{errorRate("0.2", "0.01")},
+					},
+					baseURL: app.URL,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+			},
+		},
+		"error_metric_threshold_breached": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					config: &dekopin.WatchConfig{
+						Bake:     time.Minute,
+						Interval: 10 * time.Millisecond,
+						Metrics:  []dekopin.MetricCheck{errorRate("0.01", "0.2")},
+					},
+					baseURL: app.URL,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "error rate: 0.2 is above 0.05")
+			},
+		},
+		"error_http_probe_failing": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					config: &dekopin.WatchConfig{
+						Bake:        time.Minute,
+						Interval:    10 * time.Millisecond,
+						MaxFailures: 3,
+						HTTP:        []dekopin.SmokeCheck{{Path: "/broken"}},
+					},
+					baseURL: app.URL,
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.ErrorContains(t, result.Err, "expected status 200, got 500")
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{
+				Err: dekopin.WatchHealth(context.Background(), ar.config, app.Client(), ar.baseURL, map[string]string{"revision": "svc-b"}),
+			})
+		})
+	}
+}
+
+func TestTrafficSplit(t *testing.T) {
+	type TestResult struct {
+		Split map[string]int32
+	}
+
+	type ArrangeResult struct {
+		service *runpb.Service
+	}
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_skips_tag_only_targets_and_keys_latest": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{service: &runpb.Service{
+					Traffic: []*runpb.TrafficTarget{
+						{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "svc-a", Percent: 90},
+						{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST, Percent: 10},
+						{Type: runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION, Revision: "svc-b", Tag: "qa"},
+					},
+				}}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.Equal(t, map[string]int32{"svc-a": 90, "LATEST": 10}, result.Split)
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+			c.Assert(t, ar, TestResult{Split: dekopin.TrafficSplit(ar.service)})
+		})
+	}
+}
+
+func TestWithWatchTimeout(t *testing.T) {
+	type TestResult struct {
+		Remaining []time.Duration
+		Err       error
+	}
+
+	type ArrangeResult struct {
+		watch *dekopin.WatchConfig
+	}
+
+	// The bake period outlasts the command TIMEOUT.
+	const bake = 10 * time.Minute
+
+	cases := map[string]TestCase[any, ArrangeResult, TestResult]{
+		"success_every_region_gets_the_timeout_plus_the_bake_period": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{
+					watch: &dekopin.WatchConfig{Bake: bake, HTTP: []dekopin.SmokeCheck{{Path: "/healthz"}}},
+				}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Len(t, result.Remaining, 2)
+				for _, remaining := range result.Remaining {
+					assert.Greater(t, remaining, bake)
+					assert.LessOrEqual(t, remaining, dekopin.TIMEOUT+bake)
+				}
+			},
+		},
+		"success_without_watch_every_region_gets_the_timeout": {
+			Arrange: func() ArrangeResult {
+				return ArrangeResult{}
+			},
+			Assert: func(t *testing.T, assertArgs ArrangeResult, result TestResult) {
+				assert.NoError(t, result.Err)
+				assert.Len(t, result.Remaining, 2)
+				for _, remaining := range result.Remaining {
+					assert.LessOrEqual(t, remaining, dekopin.TIMEOUT)
+				}
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ar := c.Arrange()
+
+			ctx := dekopin.SetCmdOption(context.Background(), &dekopin.CmdOption{
+				Project: "test-project",
+				Service: "test-service",
+				Runner:  dekopin.RUNNER_LOCAL,
+				Regions: []string{"asia-northeast1", "us-central1"},
+				Watch:   ar.watch,
+				History: &dekopin.HistoryConfig{Store: dekopin.HISTORY_STORE_NONE},
+			})
+			ctx = dekopin.WithOutput(ctx, io.Discard, io.Discard)
+
+			ctx, err := dekopin.WithWatchTimeout(ctx)
+			if err != nil {
+				c.Assert(t, ar, TestResult{Err: err})
+				return
+			}
+
+			remaining := []time.Duration{}
+			err = dekopin.RunForEachRegion(ctx, false, func(ctx context.Context) error {
+				deadline, _ := ctx.Deadline()
+				remaining = append(remaining, time.Until(deadline))
+				return nil
+			})
+
+			c.Assert(t, ar, TestResult{
+				Remaining: remaining,
+				Err:       err,
+			})
+		})
+	}
+}